@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+// defaultAccessLevel is the minimum access level of the collaborators of project.
+const defaultAccessLevel = gitlab.DeveloperPermissions
+
+var accessLevels = map[string]gitlab.AccessLevelValue{
+	"guest":      gitlab.GuestPermissions,
+	"reporter":   gitlab.ReporterPermissions,
+	"developer":  gitlab.DeveloperPermissions,
+	"maintainer": gitlab.MaintainerPermissions,
+	"owner":      gitlab.OwnerPermissions,
+}
+
+func accessLevelName(level gitlab.AccessLevelValue) string {
+	for k, v := range accessLevels {
+		if v == level {
+			return strings.Title(k)
+		}
+	}
+
+	return fmt.Sprintf("%d", level)
+}
+
+// parseCommandAccessLevels parses the minimum access levels of commands.
+func parseCommandAccessLevels(levels map[string]string) (map[string]gitlab.AccessLevelValue, error) {
+	if len(levels) == 0 {
+		return nil, nil
+	}
+
+	r := make(map[string]gitlab.AccessLevelValue, len(levels))
+
+	for cmd, name := range levels {
+		canonical := canonicalCommandName(cmd)
+		if canonical == "" {
+			return nil, fmt.Errorf("unknown command of command_access_levels:%s", cmd)
+		}
+
+		v, ok := accessLevels[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported access level of command %s:%s", cmd, name)
+		}
+
+		r[canonical] = v
+	}
+
+	return r, nil
+}
+
+// canonicalCommandName returns the name of command whose alias is the specified one.
+// It returns empty if the command is not registered.
+func canonicalCommandName(name string) string {
+	for _, spec := range commandRegistry() {
+		if spec.matches(command{name: strings.ToLower(name)}) {
+			return spec.names[0]
+		}
+	}
+
+	return ""
+}
+
+// accessLevelOf returns the minimum access level of project to use the command.
+// It returns false if it is not configured explicitly.
+func (c *botConfig) accessLevelOf(cmd string) (gitlab.AccessLevelValue, bool) {
+	if v, ok := c.accessLevels[cmd]; ok {
+		return v, true
+	}
+
+	return defaultAccessLevel, false
+}
+
+// isCollaborator checks whether the user has the access level of project required by the command.
+// The access level includes the one inherited from the groups.
+func (bot *robot) isCollaborator(cfg *botConfig, cmd string, pid, userID int) (bool, error) {
+	level, err := bot.cli.GetUserAccessLevelOfProject(pid, userID)
+	if err != nil {
+		return false, err
+	}
+
+	min, _ := cfg.accessLevelOf(cmd)
+
+	return level >= min, nil
+}
@@ -1,17 +1,13 @@
 package main
 
 import (
-	"fmt"
 	"github.com/opensourceways/community-robot-lib/gitlabclient"
 	"github.com/xanzy/go-gitlab"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"strings"
 )
 
-const (
-	retestCommand     = "/retest"
-	msgNotSetReviewer = "**@%s** Thank you for submitting a PullRequest. It is detected that you have not set a reviewer, please set a one."
-)
+const retestCommand = "/retest"
 
 func (bot *robot) doRetest(e *gitlab.MergeEvent) error {
 	if e.ObjectAttributes.State != "opened" || !gitlabclient.CheckSourceBranchChanged(e) {
@@ -39,38 +35,61 @@ func (bot *robot) checkReviewer(e *gitlab.MergeEvent, cfg *botConfig) error {
 
 	return bot.cli.CreateMergeRequestComment(
 		pid, mrID,
-		fmt.Sprintf(msgNotSetReviewer, author),
+		cfg.message(msgNotSetReviewer, messageArgs{"Author": author}),
 	)
 }
 
-func (bot *robot) clearLabel(e *gitlab.MergeEvent) error {
+func (bot *robot) clearLabel(e *gitlab.MergeEvent, cfg *botConfig) error {
 	if e.ObjectAttributes.State != "opened" || !gitlabclient.CheckSourceBranchChanged(e) {
 		return nil
 	}
 
 	pid := e.Project.ID
 	mrID := e.ObjectAttributes.IID
+
+	if bot.rebases.isRebasedByBot(pid, mrID, e.ObjectAttributes.OldRev) {
+		return nil
+	}
+
 	labelSet := sets.NewString()
 	mrLabels, err := bot.cli.GetMergeRequestLabels(pid, mrID)
 	if err != nil {
 		return err
 	}
 	labelSet.Insert(mrLabels...)
-	v := getLGTMLabelsOnPR(labelSet)
+
+	var cleared []string
+	if cfg.LgtmCountsRequired > 1 {
+		// the summary label of lgtm is removed together with the lgtm records.
+		ok, err := bot.clearLGTMRecords(cfg, pid, mrID, e.ObjectAttributes.AuthorID)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			cleared = append(cleared, lgtmLabel)
+		}
+	}
+
+	var v []string
+	if cfg.LgtmCountsRequired <= 1 && labelSet.Has(lgtmLabel) {
+		v = append(v, lgtmLabel)
+	}
 
 	if labelSet.Has(approvedLabel) {
 		v = append(v, approvedLabel)
 	}
 
 	if len(v) > 0 {
-
 		if err := bot.cli.RemoveMergeRequestLabel(pid, mrID, v); err != nil {
 			return err
 		}
+	}
 
-		return bot.cli.CreateMergeRequestComment(
-			pid, mrID,
-			fmt.Sprintf(commentClearLabel, strings.Join(v, ", ")),
+	if cleared = append(cleared, v...); len(cleared) > 0 {
+		return bot.postActionComment(
+			cfg, pid, mrID,
+			cfg.message(commentClearLabel, messageArgs{"Labels": strings.Join(cleared, ", ")}),
 		)
 	}
 
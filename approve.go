@@ -1,32 +1,43 @@
 package main
 
 import (
-	"fmt"
 	"github.com/opensourceways/community-robot-lib/gitlabclient"
 	"github.com/xanzy/go-gitlab"
-	"regexp"
 
 	"github.com/sirupsen/logrus"
 )
 
-const approvedLabel = "approved"
-
-var (
-	regAddApprove    = regexp.MustCompile(`(?mi)^/approved\s*$`)
-	regRemoveApprove = regexp.MustCompile(`(?mi)^/approved cancel\s*$`)
+const (
+	approvedLabel = "approved"
+	cmdApprove    = "approve"
+	cmdApproved   = "approved"
 )
 
-func (bot *robot) handleApprove(e *gitlab.MergeCommentEvent, cfg *botConfig, log *logrus.Entry) error {
-	if e.MergeRequest.State != gitlabclient.ActionOpened || e.ObjectKind != "note" {
+var approveCommand = commandSpec{
+	names:       []string{cmdApprove, cmdApproved},
+	syntax:      "/approve [cancel]",
+	examples:    []string{"/approve", "/approve cancel"},
+	description: "Add or remove the `approved` label for a pull request, it is used to determine whether the pull request can be merged.",
+	whoCanUse: func(cfg *botConfig) string {
+		if len(cfg.ApproveRoles) > 0 {
+			return whoHasRoles(cfg, cmdApprove, cfg.ApproveRoles) + "."
+		}
+
+		return whoHasPermission(cfg, cmdApprove, false) + "."
+	},
+	handle: (*robot).handleApprove,
+}
+
+func (bot *robot) handleApprove(cmd command, e *gitlab.MergeCommentEvent, cfg *botConfig, log *logrus.Entry) error {
+	if e.MergeRequest.State != gitlabclient.ActionOpened {
 		return nil
 	}
 
-	comment := gitlabclient.GetMRCommentBody(e)
-	if regAddApprove.MatchString(comment) {
+	if cmd.hasArgs() {
 		return bot.AddApprove(cfg, e, log)
 	}
 
-	if regRemoveApprove.MatchString(comment) {
+	if cmd.hasArgs("cancel") {
 		return bot.removeApprove(cfg, e, log)
 	}
 
@@ -34,20 +45,24 @@ func (bot *robot) handleApprove(e *gitlab.MergeCommentEvent, cfg *botConfig, log
 }
 
 func (bot *robot) AddApprove(cfg *botConfig, e *gitlab.MergeCommentEvent, log *logrus.Entry) error {
-	org, repo := gitlabclient.GetMRCommentOrgAndRepo(e)
 	commenter := gitlabclient.GetMRCommentAuthor(e)
-	commenterID := gitlabclient.GetMRCommentAuthorID(e)
 	number := e.MergeRequest.IID
 	pid := e.ProjectID
 
-	v, err := bot.hasPermission(org, repo, commenter, commenterID, false, e, cfg, log)
+	v, eligible, err := bot.checkPermission(cmdApprove, cfg.ApproveRoles, false, e, cfg, log)
 	if err != nil {
 		return err
 	}
 
 	if !v {
-		return bot.cli.CreateMergeRequestComment(pid, number, fmt.Sprintf(
-			commentNoPermissionForLabel, commenter, "add", approvedLabel,
+		return bot.cli.CreateMergeRequestComment(pid, number, cfg.message(
+			commentNoPermissionForLabel,
+			messageArgs{
+				"Commenter": commenter,
+				"Action":    "add",
+				"Label":     approvedLabel,
+				"Eligible":  formatEligible(eligible),
+			},
 		))
 	}
 
@@ -55,9 +70,9 @@ func (bot *robot) AddApprove(cfg *botConfig, e *gitlab.MergeCommentEvent, log *l
 		return err
 	}
 
-	err = bot.cli.CreateMergeRequestComment(
-		pid, number,
-		fmt.Sprintf(commentAddLabel, approvedLabel, commenter),
+	err = bot.postActionComment(
+		cfg, pid, number,
+		cfg.message(commentAddLabel, messageArgs{"Label": approvedLabel, "Commenter": commenter}),
 	)
 	if err != nil {
 		log.Error(err)
@@ -67,20 +82,24 @@ func (bot *robot) AddApprove(cfg *botConfig, e *gitlab.MergeCommentEvent, log *l
 }
 
 func (bot *robot) removeApprove(cfg *botConfig, e *gitlab.MergeCommentEvent, log *logrus.Entry) error {
-	org, repo := gitlabclient.GetMRCommentOrgAndRepo(e)
 	commenter := gitlabclient.GetMRCommentAuthor(e)
-	commenterID := gitlabclient.GetMRCommentAuthorID(e)
 	number := e.MergeRequest.IID
 	pid := e.ProjectID
 
-	v, err := bot.hasPermission(org, repo, commenter, commenterID, false, e, cfg, log)
+	v, eligible, err := bot.checkPermission(cmdApprove, cfg.ApproveRoles, false, e, cfg, log)
 	if err != nil {
 		return err
 	}
 
 	if !v {
-		return bot.cli.CreateMergeRequestComment(pid, number, fmt.Sprintf(
-			commentNoPermissionForLabel, commenter, "remove", approvedLabel,
+		return bot.cli.CreateMergeRequestComment(pid, number, cfg.message(
+			commentNoPermissionForLabel,
+			messageArgs{
+				"Commenter": commenter,
+				"Action":    "remove",
+				"Label":     approvedLabel,
+				"Eligible":  formatEligible(eligible),
+			},
 		))
 	}
 
@@ -89,8 +108,8 @@ func (bot *robot) removeApprove(cfg *botConfig, e *gitlab.MergeCommentEvent, log
 		return err
 	}
 
-	return bot.cli.CreateMergeRequestComment(
-		pid, number,
-		fmt.Sprintf(commentRemovedLabel, approvedLabel, commenter),
+	return bot.postActionComment(
+		cfg, pid, number,
+		cfg.message(commentRemovedLabel, messageArgs{"Label": approvedLabel, "Commenter": commenter}),
 	)
 }
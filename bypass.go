@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/opensourceways/community-robot-lib/gitlabclient"
+	"github.com/opensourceways/community-robot-lib/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	actionMerge = "merge"
+
+	bypassReactionComment = "comment"
+	bypassReactionIssue   = "issue"
+	bypassReactionWebhook = "webhook"
+
+	defaultBypassLabel = "merged-without-review"
+	webhookTimeout     = 10 * time.Second
+)
+
+// bypassedMergeConfig specifies how to react when a PR is merged around the robot.
+type bypassedMergeConfig struct {
+	// Reactions are the ways to react. Valid options are comment, issue and webhook.
+	// comment adds a comment and the label to the PR, issue opens a tracking issue
+	// in the project and webhook posts a notification to WebhookURL.
+	// The default value is comment.
+	Reactions []string `json:"reactions,omitempty"`
+
+	// Label is added to the PR by the comment reaction. The default value is merged-without-review.
+	Label string `json:"label,omitempty"`
+
+	// WebhookURL is the endpoint to be notified. It must be set when the webhook reaction is used.
+	WebhookURL string `json:"webhook_url,omitempty"`
+}
+
+func (c *bypassedMergeConfig) setDefault() {
+	if len(c.Reactions) == 0 {
+		c.Reactions = []string{bypassReactionComment}
+	}
+
+	if c.Label == "" {
+		c.Label = defaultBypassLabel
+	}
+}
+
+func (c *bypassedMergeConfig) validate() error {
+	for _, v := range c.Reactions {
+		switch v {
+		case bypassReactionComment, bypassReactionIssue:
+		case bypassReactionWebhook:
+			if c.WebhookURL == "" {
+				return fmt.Errorf("missing webhook_url of bypassed_merge")
+			}
+		default:
+			return fmt.Errorf("unsupported reaction of bypassed_merge:%s", v)
+		}
+	}
+
+	return nil
+}
+
+// bypassedMergeNotification is the payload posted to the webhook.
+type bypassedMergeNotification struct {
+	Project  string   `json:"project"`
+	IID      int      `json:"iid"`
+	URL      string   `json:"url"`
+	Title    string   `json:"title"`
+	MergedBy string   `json:"merged_by"`
+	Reasons  []string `json:"reasons"`
+}
+
+// handleMerged checks the PR which has just been merged, and reacts when it was not
+// merged by the robot or it does not satisfy the review policy.
+func (bot *robot) handleMerged(e *gitlab.MergeEvent, cfg *botConfig, log *logrus.Entry) error {
+	if cfg.BypassedMerge == nil || e.ObjectAttributes.Action != actionMerge || e.User == nil {
+		return nil
+	}
+
+	org, _ := gitlabclient.GetMROrgAndRepo(e)
+	pid := e.Project.ID
+	number := e.ObjectAttributes.IID
+	mergedBy := e.User.Username
+
+	login, err := bot.directory.botLogin(bot.cli)
+	if err != nil {
+		return err
+	}
+
+	// the robot merges PR only after it has checked the review policy, including
+	// the freeze of target branch which depends on who triggered the merge.
+	if strings.EqualFold(mergedBy, login) {
+		return nil
+	}
+
+	mr, err := bot.cli.GetMergeRequest(pid, number)
+	if err != nil {
+		return err
+	}
+
+	h := mergeHelper{
+		cfg:       cfg,
+		pid:       pid,
+		mrID:      number,
+		org:       org,
+		author:    mr.Author.Username,
+		cli:       bot.cli,
+		directory: bot.directory,
+		mr:        &mr,
+		trigger:   mergedBy,
+	}
+
+	reasons, _ := h.checkPolicy(log)
+	reasons = append(reasons, cfg.message(msgNotMergedByRobot, messageArgs{"MergedBy": mergedBy}))
+
+	log.Infof("PR:%d was merged by %s around the robot, reasons: %s", number, mergedBy, strings.Join(reasons, " "))
+
+	n := bypassedMergeNotification{
+		Project:  e.Project.PathWithNamespace,
+		IID:      number,
+		URL:      mr.WebURL,
+		Title:    mr.Title,
+		MergedBy: mergedBy,
+		Reasons:  reasons,
+	}
+
+	merr := utils.NewMultiErrors()
+
+	for _, v := range cfg.BypassedMerge.Reactions {
+		var err error
+
+		switch v {
+		case bypassReactionComment:
+			err = bot.reactByComment(pid, cfg, &n)
+		case bypassReactionIssue:
+			err = bot.reactByIssue(pid, cfg, &n)
+		case bypassReactionWebhook:
+			err = notifyWebhook(cfg.BypassedMerge.WebhookURL, &n)
+		}
+
+		if err != nil {
+			merr.AddError(fmt.Errorf("react by %s, err:%s", v, err.Error()))
+		}
+	}
+
+	return merr.Err()
+}
+
+func (bot *robot) reactByComment(pid int, cfg *botConfig, n *bypassedMergeNotification) error {
+	label := cfg.BypassedMerge.Label
+
+	if err := bot.createLabelIfNeed(pid, label); err != nil {
+		return err
+	}
+
+	if err := bot.cli.AddMergeRequestLabel(pid, n.IID, []string{label}); err != nil {
+		return err
+	}
+
+	return bot.cli.CreateMergeRequestComment(pid, n.IID, cfg.message(
+		commentMergedWithoutReview,
+		messageArgs{"MergedBy": n.MergedBy, "Reasons": strings.Join(n.Reasons, "\n")},
+	))
+}
+
+func (bot *robot) reactByIssue(pid int, cfg *botConfig, n *bypassedMergeNotification) error {
+	title := cfg.message(msgBypassIssueTitle, messageArgs{"IID": n.IID, "Title": n.Title})
+	desc := cfg.message(msgBypassIssueBody, messageArgs{
+		"IID":      n.IID,
+		"URL":      n.URL,
+		"MergedBy": n.MergedBy,
+		"Reasons":  strings.Join(n.Reasons, "\n"),
+	})
+	labels := gitlab.Labels{cfg.BypassedMerge.Label}
+
+	_, err := bot.cli.CreateIssue(pid, gitlab.CreateIssueOptions{
+		Title:       &title,
+		Description: &desc,
+		Labels:      &labels,
+	})
+
+	return err
+}
+
+func notifyWebhook(url string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	cli := http.Client{Timeout: webhookTimeout}
+
+	resp, err := cli.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook responded with status:%d", resp.StatusCode)
+	}
+
+	return nil
+}
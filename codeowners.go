@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// codeOwnersFiles are the locations of CODEOWNERS file in the order of precedence.
+var codeOwnersFiles = []string{"CODEOWNERS", ".gitlab/CODEOWNERS", "docs/CODEOWNERS"}
+
+var (
+	regCodeOwnersSection = regexp.MustCompile(`^(\^)?\[([^\]]+)\](?:\[(\d+)\])?(?:\s+(.*))?$`)
+	regCodeOwnersField   = regexp.MustCompile(`(?:\\ |\S)+`)
+)
+
+// codeOwnersRule is a line of CODEOWNERS file, such as '/docs/ @alice @group/docs'.
+type codeOwnersRule struct {
+	pattern string
+	reg     *regexp.Regexp
+	owners  []string
+}
+
+// codeOwnersSection is a section of CODEOWNERS file, such as '^[Docs][2] @docs-team'.
+// The rules before the first section belong to the default section whose name is empty.
+type codeOwnersSection struct {
+	name string
+
+	// optional means the approval of the section is not required to merge PR.
+	optional bool
+
+	// approvals is the number of approvals required from the owners of the section.
+	approvals int
+
+	// owners are the default owners of the rules which do not specify any.
+	owners []string
+
+	rules []codeOwnersRule
+}
+
+// ownersOf returns the owners of file specified by the last matching rule of section.
+func (s *codeOwnersSection) ownersOf(file string) ([]string, bool) {
+	for i := len(s.rules) - 1; i >= 0; i-- {
+		rule := &s.rules[i]
+		if !rule.reg.MatchString(file) {
+			continue
+		}
+
+		if len(rule.owners) > 0 {
+			return rule.owners, true
+		}
+
+		return s.owners, true
+	}
+
+	return nil, false
+}
+
+type codeOwners struct {
+	sections []codeOwnersSection
+}
+
+// codeOwnersOfFiles is the owners of the files changed by PR which are matched by the same rule of a section.
+type codeOwnersOfFiles struct {
+	section   string
+	optional  bool
+	approvals int
+
+	// owners are the entries of CODEOWNERS file, such as '@alice' and '@group/docs'.
+	owners []string
+}
+
+// ownersOf returns the owners of the files by section. Each file is owned by the last matching
+// rule of a section, and the files owned by the same owners of a section are grouped together,
+// so that the owners of a rule can not approve the files of the other rules.
+func (c *codeOwners) ownersOf(files []string) []codeOwnersOfFiles {
+	var r []codeOwnersOfFiles
+
+	for i := range c.sections {
+		s := &c.sections[i]
+
+		seen := sets.NewString()
+
+		for _, f := range files {
+			v, ok := s.ownersOf(f)
+			if !ok || len(v) == 0 {
+				continue
+			}
+
+			owners := sets.NewString(v...).List()
+			if k := strings.ToLower(strings.Join(owners, " ")); !seen.Has(k) {
+				seen.Insert(k)
+
+				r = append(r, codeOwnersOfFiles{
+					section:   s.name,
+					optional:  s.optional,
+					approvals: s.approvals,
+					owners:    owners,
+				})
+			}
+		}
+	}
+
+	return r
+}
+
+// ownersOfFile returns the owners of file specified by the last matching rule of each section.
+func (c *codeOwners) ownersOfFile(file string) []string {
+	owners := sets.NewString()
+
+	for i := range c.sections {
+		if v, ok := c.sections[i].ownersOf(file); ok {
+			owners.Insert(v...)
+		}
+	}
+
+	return owners.List()
+}
+
+func parseCodeOwners(content string) (codeOwners, error) {
+	var c codeOwners
+
+	index := map[string]int{}
+	current := -1
+
+	sectionOf := func(name string) *codeOwnersSection {
+		k := strings.ToLower(name)
+		if i, ok := index[k]; ok {
+			current = i
+		} else {
+			c.sections = append(c.sections, codeOwnersSection{name: name, approvals: 1})
+			current = len(c.sections) - 1
+			index[k] = current
+		}
+
+		return &c.sections[current]
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if m := regCodeOwnersSection.FindStringSubmatch(line); m != nil {
+			s := sectionOf(strings.TrimSpace(m[2]))
+			s.optional = m[1] != ""
+
+			if m[3] != "" {
+				v, err := strconv.Atoi(m[3])
+				if err != nil || v <= 0 {
+					return c, fmt.Errorf("invalid approvals of section at line %d", n)
+				}
+
+				s.approvals = v
+			}
+
+			if m[4] != "" {
+				s.owners = strings.Fields(m[4])
+			}
+
+			continue
+		}
+
+		fields := regCodeOwnersField.FindAllString(line, -1)
+		pattern := strings.ReplaceAll(strings.TrimPrefix(fields[0], `\`), `\ `, " ")
+
+		reg, err := codeOwnersPatternToRegexp(pattern)
+		if err != nil {
+			return c, fmt.Errorf("invalid pattern at line %d, err:%s", n, err.Error())
+		}
+
+		if current < 0 {
+			sectionOf("")
+		}
+
+		s := &c.sections[current]
+		s.rules = append(s.rules, codeOwnersRule{pattern: pattern, reg: reg, owners: fields[1:]})
+	}
+
+	return c, scanner.Err()
+}
+
+// codeOwnersPatternToRegexp converts the pattern of CODEOWNERS file to a regexp matching
+// the paths of files. A pattern not starting with '/' matches in any directory, and
+// a pattern matching a directory matches all of the files in it.
+func codeOwnersPatternToRegexp(pattern string) (*regexp.Regexp, error) {
+	p := pattern
+
+	prefix := "^(?:.*/)?"
+	if strings.HasPrefix(p, "/") {
+		prefix = "^"
+		p = strings.TrimPrefix(p, "/")
+	}
+
+	p = strings.TrimSuffix(p, "/")
+
+	b := strings.Builder{}
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+
+		case strings.HasPrefix(p[i:], "**"):
+			b.WriteString(".*")
+			i++
+
+		case p[i] == '*':
+			b.WriteString("[^/]*")
+
+		case p[i] == '?':
+			b.WriteString("[^/]")
+
+		default:
+			b.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+
+	return regexp.Compile(prefix + b.String() + "(?:/.*)?$")
+}
+
+// loadCodeOwners loads the CODEOWNERS file of the branch. It returns nil if there is no such file.
+func loadCodeOwners(cli iClient, pid int, branch string) (*codeOwners, error) {
+	for _, name := range codeOwnersFiles {
+		f, err := cli.GetPathContent(pid, name, branch)
+		if err != nil || f == nil {
+			continue
+		}
+
+		content, err := base64.StdEncoding.DecodeString(f.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		c, err := parseCodeOwners(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("parse %s, err:%s", name, err.Error())
+		}
+
+		return &c, nil
+	}
+
+	return nil, nil
+}
+
+// expandCodeOwners returns the usernames in lower case referenced by the owners of CODEOWNERS file.
+// An owner like '@name' is a group if it exists, otherwise a user. The owners of email are ignored.
+func (r *ownerResolver) expandCodeOwners(owners []string) sets.String {
+	people := sets.NewString()
+
+	for _, v := range owners {
+		if !strings.HasPrefix(v, ownerGroupPrefix) {
+			continue
+		}
+
+		name := strings.TrimPrefix(v, ownerGroupPrefix)
+
+		members, err := r.directory.membersOf(r.cli, name)
+		if err != nil {
+			r.log.WithError(err).Errorf("get the members of group %s", name)
+		}
+
+		if members.Len() > 0 {
+			people.Insert(members.UnsortedList()...)
+		} else if !strings.Contains(name, "/") {
+			people.Insert(strings.ToLower(name))
+		}
+	}
+
+	return people
+}
+
+// codeOwnersOfPR returns the code owners of the files changed by PR by section.
+func codeOwnersOfPR(cli iClient, pid, mrID int, branch string) ([]codeOwnersOfFiles, error) {
+	c, err := loadCodeOwners(cli, pid, branch)
+	if err != nil || c == nil {
+		return nil, err
+	}
+
+	changes, err := cli.GetMergeRequestChanges(pid, mrID)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.ownersOf(changes), nil
+}
+
+// checkCodeOwners checks whether the files changed by PR are approved by their owners in each
+// required section of CODEOWNERS. It returns the reasons for the missing approvals.
+func (m *mergeHelper) checkCodeOwners(log *logrus.Entry) []string {
+	if !sets.NewString(m.cfg.ApproveRoles...).Has(roleCodeOwner) {
+		return nil
+	}
+
+	sections, err := codeOwnersOfPR(m.cli, m.pid, m.mrID, m.mr.TargetBranch)
+	if err != nil {
+		log.WithError(err).Error("get the code owners of PR")
+
+		return []string{err.Error()}
+	}
+
+	if len(sections) == 0 {
+		return nil
+	}
+
+	approvers, err := m.approvers()
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	resolver := newOwnerResolver(m.cli, m.directory, m.cfg, m.pid, m.mr.TargetBranch, log)
+
+	var reasons []string
+
+	for i := range sections {
+		s := &sections[i]
+		if s.optional {
+			continue
+		}
+
+		owners := resolver.expandCodeOwners(s.owners)
+		got := approvers.Intersection(owners)
+
+		if got.Len() >= s.approvals {
+			continue
+		}
+
+		name := s.section
+		if name == "" {
+			name = "default"
+		}
+
+		reasons = append(reasons, m.cfg.message(msgCodeOwnersNotApproved, messageArgs{
+			"Section":  name,
+			"Required": s.approvals,
+			"Got":      got.Len(),
+			"Owners":   strings.Join(s.owners, ", "),
+		}))
+	}
+
+	return reasons
+}
+
+// approvers returns the people who have given /approve to the latest commits of PR and not
+// canceled it except the author of PR, which are in lower case. The rebase of robot only replays
+// the approved commits, so the approvals given before it are still in effect.
+func (m *mergeHelper) approvers() (sets.String, error) {
+	r := sets.NewString()
+
+	if !m.getMRLabels().Has(approvedLabel) {
+		return r, nil
+	}
+
+	notes, err := m.cli.ListMergeRequestComments(m.pid, m.mrID)
+	if err != nil {
+		return nil, err
+	}
+
+	bot, err := m.directory.botLogin(m.cli)
+	if err != nil {
+		return nil, err
+	}
+
+	var pushed time.Time
+	for _, n := range notes {
+		if isPushNote(n) && n.CreatedAt != nil && n.CreatedAt.After(pushed) &&
+			!strings.EqualFold(n.Author.Username, bot) {
+			pushed = *n.CreatedAt
+		}
+	}
+
+	for u, n := range newReviewState(notes, m.mr.Author.ID).approveNotes {
+		if n.CreatedAt.After(pushed) && !strings.EqualFold(u, m.author) {
+			r.Insert(strings.ToLower(u))
+		}
+	}
+
+	return r, nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/opensourceways/community-robot-lib/gitlabclient"
+	"github.com/opensourceways/community-robot-lib/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	whoCollaborators = "Collaborators of this repository"
+	whoSigOwners     = "owners of the sig directories changed by the pull request"
+	whoAnyone        = "Anyone"
+
+	whoMembersWithLevel = "Members of this repository with the %s access level or higher"
+)
+
+var regCommandName = regexp.MustCompile(`^[a-z][-a-z0-9_]*$`)
+
+// command is a command parsed from a comment, such as '/lgtm cancel'.
+type command struct {
+	name string
+	args []string
+}
+
+// hasArgs reports whether the arguments of command are exactly the ones specified.
+func (c command) hasArgs(args ...string) bool {
+	if len(c.args) != len(args) {
+		return false
+	}
+
+	for i := range args {
+		if !strings.EqualFold(c.args[i], args[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c command) String() string {
+	return strings.Join(append([]string{"/" + c.name}, c.args...), " ")
+}
+
+// parseCommands tokenizes a comment into commands in the order of appearance.
+// A command must start a line. The lines in fenced or indented code blocks and
+// quoted replies are ignored. When a command is given more than once, only the
+// last one counts, so that '/lgtm cancel' followed by '/lgtm' means lgtm.
+func parseCommands(note string) []command {
+	var all []command
+
+	inFence := false
+
+	for _, raw := range strings.Split(note, "\n") {
+		line := strings.TrimSpace(raw)
+
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			inFence = !inFence
+
+			continue
+		}
+
+		if inFence || isIndentedCode(raw) || !strings.HasPrefix(line, "/") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		name := strings.ToLower(strings.TrimPrefix(fields[0], "/"))
+		if !regCommandName.MatchString(name) {
+			continue
+		}
+
+		all = append(all, command{name: name, args: fields[1:]})
+	}
+
+	last := make(map[string]int, len(all))
+	for i := range all {
+		last[all[i].name] = i
+	}
+
+	cmds := make([]command, 0, len(last))
+	for i := range all {
+		if last[all[i].name] == i {
+			cmds = append(cmds, all[i])
+		}
+	}
+
+	return cmds
+}
+
+// isIndentedCode reports whether the line is in an indented code block of markdown.
+func isIndentedCode(line string) bool {
+	return strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")
+}
+
+type commandHandler func(*robot, command, *gitlab.MergeCommentEvent, *botConfig, *logrus.Entry) error
+
+// commandSpec declares how a comment command is used. It is the source of
+// the command table posted by /help, so it must be kept in step with the handler.
+type commandSpec struct {
+	// names are the name of command and its aliases, such as 'approve' and 'approved'.
+	names []string
+
+	// syntax is the usage of command, such as '/lgtm [cancel]'.
+	syntax string
+
+	// examples are the concrete comments which trigger the command.
+	examples []string
+
+	description string
+
+	// whoCanUse describes the permission requirement under the config of repository.
+	whoCanUse func(cfg *botConfig) string
+
+	handle commandHandler
+}
+
+func (c *commandSpec) matches(cmd command) bool {
+	for _, n := range c.names {
+		if n == cmd.name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *commandSpec) tableRow(cfg *botConfig) string {
+	syntax := c.syntax
+	if len(c.names) > 1 {
+		syntax += fmt.Sprintf("<br/>alias: /%s", strings.Join(c.names[1:], ", /"))
+	}
+
+	return fmt.Sprintf(
+		"| %s | %s | %s | %s |",
+		syntax, strings.Join(c.examples, "<br/>"), c.description, c.whoCanUse(cfg),
+	)
+}
+
+func commandRegistry() []commandSpec {
+	return []commandSpec{
+		lgtmCommand,
+		approveCommand,
+		checkPRCommand,
+		rebaseCommand,
+		removeLifecycleCommand,
+		checkIdentitiesCommand,
+		helpCommand,
+	}
+}
+
+func (bot *robot) findCommand(cmd command) *commandSpec {
+	for i := range bot.commands {
+		if bot.commands[i].matches(cmd) {
+			return &bot.commands[i]
+		}
+	}
+
+	return nil
+}
+
+// handleCommands dispatches the commands of comment to the registered handlers in order.
+func (bot *robot) handleCommands(e *gitlab.MergeCommentEvent, cfg *botConfig, log *logrus.Entry) error {
+	if e.ObjectKind != "note" {
+		return nil
+	}
+
+	merr := utils.NewMultiErrors()
+
+	for _, cmd := range parseCommands(gitlabclient.GetMRCommentBody(e)) {
+		spec := bot.findCommand(cmd)
+		if spec == nil {
+			continue
+		}
+
+		if err := spec.handle(bot, cmd, e, cfg, log); err != nil {
+			merr.AddError(fmt.Errorf("handle %s, err:%s", cmd.String(), err.Error()))
+		}
+	}
+
+	return merr.Err()
+}
+
+// whoHasRoles describes the people who can use a command which is granted to the roles.
+func whoHasRoles(cfg *botConfig, cmd string, roles []string) string {
+	who := "The people with the roles: " + strings.Join(roles, ", ")
+
+	if _, ok := cfg.accessLevelOf(cmd); ok {
+		return who + ", and " + strings.ToLower(whoCollaboratorsOf(cfg, cmd))
+	}
+
+	return who
+}
+
+func whoHasPermission(cfg *botConfig, cmd string, checkSig bool) string {
+	who := whoCollaboratorsOf(cfg, cmd)
+
+	if checkSig && cfg.CheckPermissionBasedOnSigOwners {
+		return who + " and " + whoSigOwners
+	}
+
+	return who
+}
+
+// whoCollaboratorsOf describes the members of project who can use the command by their access level.
+func whoCollaboratorsOf(cfg *botConfig, cmd string) string {
+	if level, ok := cfg.accessLevelOf(cmd); ok {
+		return fmt.Sprintf(whoMembersWithLevel, accessLevelName(level))
+	}
+
+	return whoCollaborators
+}
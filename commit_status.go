@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/opensourceways/community-robot-lib/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	statusNameLGTM    = "review/lgtm"
+	statusNameApprove = "review/approve"
+	statusNameLabels  = "review/labels"
+	statusNameFreeze  = "review/freeze"
+)
+
+type reviewStatus struct {
+	name  string
+	state gitlab.BuildStateValue
+	desc  string
+}
+
+func newReviewStatus(name string, ok bool, desc string) reviewStatus {
+	state := gitlab.Failed
+	if ok {
+		state = gitlab.Success
+	}
+
+	return reviewStatus{name: name, state: state, desc: desc}
+}
+
+// publishCommitStatus sets the review statuses on the head commit of PR.
+func (m *mergeHelper) publishCommitStatus(log *logrus.Entry) error {
+	if !m.cfg.ReportCommitStatus || m.mr.SHA == "" {
+		return nil
+	}
+
+	ops, err := m.cli.GetMergeRequestLabelChanges(m.pid, m.mrID)
+	if err != nil {
+		return err
+	}
+
+	merr := utils.NewMultiErrors()
+
+	for _, s := range m.reviewStatuses(m.getMRLabels(), ops, log) {
+		name := s.name
+		desc := s.desc
+
+		opts := gitlab.SetCommitStatusOptions{
+			State:       s.state,
+			Name:        &name,
+			Description: &desc,
+		}
+
+		// the source branch is not in the target project when the PR comes from a fork.
+		if m.mr.SourceProjectID == m.mr.TargetProjectID {
+			opts.Ref = &m.mr.SourceBranch
+		}
+
+		if err := m.cli.SetCommitStatus(m.pid, m.mr.SHA, opts); err != nil {
+			merr.AddError(fmt.Errorf("set commit status %s, err:%s", name, err.Error()))
+		}
+	}
+
+	return merr.Err()
+}
+
+func (m *mergeHelper) reviewStatuses(labels sets.String, ops []*gitlab.LabelEvent, log *logrus.Entry) []reviewStatus {
+	n := lgtmCountOnPR(labels, m.cfg)
+
+	var r []reviewStatus
+
+	if n < m.cfg.LgtmCountsRequired {
+		r = append(r, newReviewStatus(
+			statusNameLGTM, false, fmt.Sprintf("%d of %d lgtm", n, m.cfg.LgtmCountsRequired),
+		))
+	} else if v := m.checkLGTMQuorum(log); len(v) > 0 {
+		r = append(r, newReviewStatus(statusNameLGTM, false, "the lgtm quorum is not met"))
+	} else {
+		r = append(r, newReviewStatus(
+			statusNameLGTM, true, fmt.Sprintf("%d of %d lgtm", n, m.cfg.LgtmCountsRequired),
+		))
+	}
+
+	if !labels.Has(approvedLabel) {
+		r = append(r, newReviewStatus(statusNameApprove, false, "waiting for /approve"))
+	} else if v := m.checkCodeOwners(log); len(v) > 0 {
+		r = append(r, newReviewStatus(statusNameApprove, false, "waiting for the approval of code owners"))
+	} else {
+		r = append(r, newReviewStatus(statusNameApprove, true, "approved"))
+	}
+
+	bot, err := m.directory.botLogin(m.cli)
+	if err != nil {
+		log.WithError(err).Error("get the account of robot")
+	}
+
+	if v := isLabelMatched(labels, m.cfg, ops, bot, log); len(v) > 0 {
+		r = append(r, newReviewStatus(statusNameLabels, false, "the labels are not ready to merge"))
+	} else {
+		r = append(r, newReviewStatus(statusNameLabels, true, "the labels are ready to merge"))
+	}
+
+	freeze, err := m.getFreezeInfo(log)
+	switch {
+	case err != nil:
+		r = append(r, reviewStatus{
+			name: statusNameFreeze, state: gitlab.Pending, desc: "the freeze status is unknown",
+		})
+
+	case freeze == nil || !freeze.isFrozen():
+		r = append(r, newReviewStatus(statusNameFreeze, true, "the target branch is not frozen"))
+
+	case m.trigger != "" && freeze.isOwner(m.trigger):
+		r = append(r, newReviewStatus(statusNameFreeze, true, "merged by the owner of frozen branch"))
+
+	default:
+		r = append(r, newReviewStatus(
+			statusNameFreeze, false, "the target branch is frozen, only its owners can merge",
+		))
+	}
+
+	return r
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"text/template"
 
 	"github.com/opensourceways/community-robot-lib/config"
+	"github.com/xanzy/go-gitlab"
 )
 
 type pullRequestMergeMethod string
@@ -13,8 +15,28 @@ type pullRequestMergeMethod string
 const (
 	mergeMethodeMerge pullRequestMergeMethod = "merge"
 	mergeMethodSquash pullRequestMergeMethod = "squash"
+	mergeMethodRebase pullRequestMergeMethod = "rebase"
+	mergeMethodFFOnly pullRequestMergeMethod = "ff-only"
 )
 
+// mergeMethodLabelPrefix is the prefix of label which overrides the merge method for a PR.
+const mergeMethodLabelPrefix = "merge/"
+
+func (m pullRequestMergeMethod) isValid() bool {
+	switch m {
+	case mergeMethodeMerge, mergeMethodSquash, mergeMethodRebase, mergeMethodFFOnly:
+		return true
+	}
+
+	return false
+}
+
+// needRebase reports whether the PR must be rebased onto the target branch
+// before it is merged by this method.
+func (m pullRequestMergeMethod) needRebase() bool {
+	return m == mergeMethodRebase || m == mergeMethodFFOnly
+}
+
 type configuration struct {
 	ConfigItems []botConfig `json:"config_items,omitempty"`
 }
@@ -72,6 +94,26 @@ type botConfig struct {
 	// The default value is 1 which means the lgtm label is itself.
 	LgtmCountsRequired uint `json:"lgtm_counts_required,omitempty"`
 
+	// LgtmRoles are the roles whose people can /lgtm, and ApproveRoles are the ones for /approve.
+	// Valid options are collaborator, approver and reviewer, together with the roles of the
+	// sig-info files. collaborator means the collaborators of the project, and the others are
+	// resolved from the OWNERS files of the changed directories and their parents, and the
+	// sig-info files of the related sigs. The collaborators can use both commands if they are not set.
+	LgtmRoles    []string `json:"lgtm_roles,omitempty"`
+	ApproveRoles []string `json:"approve_roles,omitempty"`
+
+	// CommandAccessLevels maps a command to the minimum access level of the project, including
+	// the one inherited from the groups, which is required to use it, such as 'approve: maintainer'.
+	// Valid levels are guest, reporter, developer, maintainer and owner. The people qualified by
+	// the level are alternatives to the ones with the roles above. The default level is developer.
+	CommandAccessLevels map[string]string                  `json:"command_access_levels,omitempty"`
+	accessLevels        map[string]gitlab.AccessLevelValue `json:"-"`
+
+	// LgtmQuorum are the rules on who must be among the people giving lgtm, such as
+	// at least one committer of the changed sig. Each of them must be satisfied.
+	// The roles and organizations are read from the sig-info files under SigsDir.
+	LgtmQuorum []quorumRule `json:"lgtm_quorum,omitempty"`
+
 	// CheckPermissionBasedOnSigOwners means it should check the devepler's permission
 	// besed on the owners file in sig directory when the developer comment /lgtm or /approve
 	// command. The repository is 'tc' at present.
@@ -81,6 +123,16 @@ type botConfig struct {
 	SigsDir   string        `json:"sigs_dir,omitempty"`
 	regSigDir regexp.Regexp `json:"-"`
 
+	// SigsRepo is the repository holding the sig-info files, such as 'openeuler/community'.
+	// They are read from the target branch of PR if it is not set.
+	SigsRepo string `json:"sigs_repo,omitempty"`
+
+	// SigsBranch is the branch of SigsRepo. The default value is master.
+	SigsBranch string `json:"sigs_branch,omitempty"`
+
+	// SigLayout specifies how the directories of sigs are organized under SigsDir.
+	SigLayout sigLayout `json:"sig_layout,omitempty"`
+
 	// LabelsForMerge specifies the labels except approved and lgtm relevant labels
 	// that must be available to merge pr
 	LabelsForMerge []string `json:"labels_for_merge,omitempty"`
@@ -89,14 +141,68 @@ type botConfig struct {
 	MissingLabelsForMerge []string `json:"missing_labels_for_merge,omitempty"`
 
 	// MergeMethod is the method to merge PR.
-	// The default method of merge. Valid options are squash and merge.
+	// The default method of merge. Valid options are squash, merge, rebase and ff-only.
+	// rebase rebases the PR onto the target branch before merging it, and ff-only does
+	// the same but also requires the project to only accept fast-forward merges.
+	// It can be overridden for a PR by a label of 'merge/<method>', such as merge/squash.
 	MergeMethod pullRequestMergeMethod `json:"merge_method,omitempty"`
 
+	// RemoveSourceBranch means the source branch will be removed after the PR is merged.
+	// The source branch is also removed when the author of PR requires it.
+	RemoveSourceBranch bool `json:"remove_source_branch,omitempty"`
+
+	// AutoRebase means the robot will rebase the PR onto the target branch before merging it
+	// when the project only accepts fast-forward merges and the PR is behind the target branch.
+	AutoRebase bool `json:"auto_rebase,omitempty"`
+
 	// UnableCheckingReviewerForPR is a switch used to check whether the pr has been set reviewers when it is open.
 	UnableCheckingReviewerForPR bool `json:"unable_checking_reviewer_for_pr,omitempty"`
 
 	// FreezeFile is the freeze branch of community
 	FreezeFile []freezeFile `json:"freeze_file,omitempty"`
+
+	// MergeCommitTemplate is the Go text/template of the message of merge commit, which is
+	// also used as the squash commit message. The variables are listed in mergeCommitArgs.
+	// The default template lists the reviewers and approvers as commit trailers.
+	MergeCommitTemplate string             `json:"merge_commit_template,omitempty"`
+	mergeCommitTmpl     *template.Template `json:"-"`
+
+	// StickyStatusComment means the robot maintains one review status comment in the PR and
+	// updates it in place on every event instead of posting a new comment for each action.
+	StickyStatusComment bool `json:"sticky_status_comment,omitempty"`
+
+	// ReportCommitStatus means the robot publishes the review state as the external commit
+	// statuses of the PR head, such as review/lgtm, so that the project can require them
+	// to succeed before anyone merges the PR.
+	ReportCommitStatus bool `json:"report_commit_status,omitempty"`
+
+	// BypassedMerge specifies how to react when a PR is merged by someone other than the robot
+	// or without satisfying the review policy. It is disabled when it is not set.
+	BypassedMerge *bypassedMergeConfig `json:"bypassed_merge,omitempty"`
+
+	// Lifecycle specifies how to deal with the PRs which have no activity for a long time.
+	// It is disabled when it is not set, and it works only when the reconciler is enabled.
+	Lifecycle *lifecycleConfig `json:"lifecycle,omitempty"`
+
+	// ReviewSLA specifies the time within which the reviewers of PR should respond.
+	// It is disabled when it is not set, and it works only when the reconciler is enabled.
+	ReviewSLA *reviewSLAConfig `json:"review_sla,omitempty"`
+
+	// ExpiredOwnersReport specifies the report of the expired owners in the sigs directory.
+	// It is disabled when it is not set, and it works only when the reconciler is enabled.
+	ExpiredOwnersReport *expiredOwnersReportConfig `json:"expired_owners_report,omitempty"`
+
+	// Identity maps the Gitee IDs in the OWNERS and sig-info files to the GitLab usernames.
+	Identity *identityConfig `json:"identity,omitempty"`
+
+	// Locale is the language of the comments posted by robot.
+	// Valid options are en and zh_CN. The default value is en.
+	Locale string `json:"locale,omitempty"`
+
+	// CommentTemplates overrides the built-in comments of the locale.
+	// The key is the name of comment, such as add_label, and the value is a Go text/template.
+	CommentTemplates map[string]string             `json:"comment_templates,omitempty"`
+	templates        map[string]*template.Template `json:"-"`
 }
 
 func (c *botConfig) setDefault() {
@@ -107,18 +213,67 @@ func (c *botConfig) setDefault() {
 	if c.MergeMethod == "" {
 		c.MergeMethod = mergeMethodeMerge
 	}
+
+	if c.Locale == "" {
+		c.Locale = localeEN
+	}
+
+	if c.BypassedMerge != nil {
+		c.BypassedMerge.setDefault()
+	}
+
+	if c.SigsRepo != "" && c.SigsBranch == "" {
+		c.SigsBranch = defaultSigsBranch
+	}
+
+	c.SigLayout.setDefault()
+
+	if c.Identity != nil {
+		c.Identity.setDefault()
+	}
+
+	if c.ExpiredOwnersReport != nil {
+		c.ExpiredOwnersReport.setDefault()
+	}
 }
 
 func (c *botConfig) validate() error {
-	if m := c.MergeMethod; m != mergeMethodeMerge && m != mergeMethodSquash {
+	if m := c.MergeMethod; !m.isValid() {
 		return fmt.Errorf("unsupported merge method:%s", m)
 	}
 
-	if c.CheckPermissionBasedOnSigOwners {
-		if c.SigsDir == "" {
-			return fmt.Errorf("missing sigs_dir")
+	if (c.CheckPermissionBasedOnSigOwners || len(c.LgtmQuorum) > 0) && c.SigsDir == "" {
+		return fmt.Errorf("missing sigs_dir")
+	}
+
+	if c.SigsRepo != "" && c.SigsDir == "" {
+		return fmt.Errorf("missing sigs_dir of sigs_repo")
+	}
+
+	for _, v := range append(append([]string{}, c.LgtmRoles...), c.ApproveRoles...) {
+		if !permissionRoles.Has(v) {
+			return fmt.Errorf("unsupported role:%s", v)
+		}
+	}
+
+	levels, err := parseCommandAccessLevels(c.CommandAccessLevels)
+	if err != nil {
+		return err
+	}
+
+	c.accessLevels = levels
+
+	if err := c.SigLayout.validate(); err != nil {
+		return err
+	}
+
+	for i := range c.LgtmQuorum {
+		if err := c.LgtmQuorum[i].validate(); err != nil {
+			return err
 		}
+	}
 
+	if c.SigsDir != "" {
 		v, err := regexp.Compile(fmt.Sprintf(
 			`^%s/[-\w]+/`,
 			strings.TrimSuffix(c.SigsDir, "/"),
@@ -130,6 +285,47 @@ func (c *botConfig) validate() error {
 		c.regSigDir = *v
 	}
 
+	templates, err := newMessageTemplates(c.Locale, c.CommentTemplates)
+	if err != nil {
+		return err
+	}
+
+	c.templates = templates
+
+	if c.mergeCommitTmpl, err = parseMergeCommitTemplate(c.MergeCommitTemplate); err != nil {
+		return fmt.Errorf("invalid merge_commit_template, err:%s", err.Error())
+	}
+
+	if c.BypassedMerge != nil {
+		if err := c.BypassedMerge.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Lifecycle != nil {
+		if err := c.Lifecycle.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.ReviewSLA != nil {
+		if err := c.ReviewSLA.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Identity != nil {
+		if err := c.Identity.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.ExpiredOwnersReport != nil {
+		if err := c.ExpiredOwnersReport.validate(); err != nil {
+			return err
+		}
+	}
+
 	for _, v := range c.FreezeFile {
 		return v.validate()
 	}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/opensourceways/community-robot-lib/gitlabclient"
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const helpTableHeader = `| command | example | description | who can use |
+| ------- | ------- | ----------- | ----------- |`
+
+var helpCommand = commandSpec{
+	names:       []string{"help"},
+	syntax:      "/help",
+	examples:    []string{"/help"},
+	description: "Show the commands available in this repository and the conditions to merge a pull request.",
+	whoCanUse: func(cfg *botConfig) string {
+		return whoAnyone + "."
+	},
+	handle: (*robot).handleHelp,
+}
+
+func (bot *robot) handleHelp(cmd command, e *gitlab.MergeCommentEvent, cfg *botConfig, log *logrus.Entry) error {
+	if !cmd.hasArgs() {
+		return nil
+	}
+
+	org, _ := gitlabclient.GetMRCommentOrgAndRepo(e)
+	number := e.MergeRequest.IID
+	pid := e.ProjectID
+
+	h := mergeHelper{
+		cfg:       cfg,
+		pid:       pid,
+		mrID:      number,
+		org:       org,
+		cli:       bot.cli,
+		directory: bot.directory,
+		mr:        &gitlab.MergeRequest{TargetBranch: e.MergeRequest.TargetBranch},
+	}
+
+	return bot.cli.CreateMergeRequestComment(pid, number, cfg.message(commentHelp, messageArgs{
+		"Commenter":  gitlabclient.GetMRCommentAuthor(e),
+		"Commands":   genCommandTable(bot.commands, cfg),
+		"Conditions": h.genMergeConditions(log),
+	}))
+}
+
+func genCommandTable(cmds []commandSpec, cfg *botConfig) string {
+	rows := make([]string, 0, len(cmds)+1)
+	rows = append(rows, helpTableHeader)
+
+	for i := range cmds {
+		rows = append(rows, cmds[i].tableRow(cfg))
+	}
+
+	return strings.Join(rows, "\n")
+}
+
+func (m *mergeHelper) genMergeConditions(log *logrus.Entry) string {
+	quote := func(v []string) string {
+		if len(v) == 0 {
+			return ""
+		}
+
+		return "`" + strings.Join(v, "`, `") + "`"
+	}
+
+	args := messageArgs{
+		"LgtmCountsRequired":    m.cfg.LgtmCountsRequired,
+		"LgtmLabel":             lgtmLabel,
+		"LabelsForMerge":        quote(append([]string{approvedLabel}, m.cfg.LabelsForMerge...)),
+		"MissingLabelsForMerge": quote(m.cfg.MissingLabelsForMerge),
+		"TargetBranch":          m.mr.TargetBranch,
+		"FreezeKnown":           true,
+		"Frozen":                false,
+		"FreezeOwners":          "",
+	}
+
+	freeze, err := m.getFreezeInfo(log)
+	if err != nil {
+		args["FreezeKnown"] = false
+	} else if freeze != nil && freeze.isFrozen() {
+		args["Frozen"] = true
+		args["FreezeOwners"] = strings.Join(freeze.Owner, ", ")
+	}
+
+	return m.cfg.message(msgMergeConditions, args)
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/opensourceways/community-robot-lib/gitlabclient"
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	cmdCheckIdentities = "check-identities"
+
+	defaultIdentityBranch = "master"
+	defaultIdentityFile   = "identities.yaml"
+)
+
+var checkIdentitiesCommand = commandSpec{
+	names:       []string{cmdCheckIdentities},
+	syntax:      "/check-identities",
+	examples:    []string{"/check-identities"},
+	description: "List the owners in the OWNERS and sig-info files related to the pull request whose GitLab usernames are not found or are taken from their Gitee IDs.",
+	whoCanUse: func(cfg *botConfig) string {
+		return whoAnyone + "."
+	},
+	handle: (*robot).handleCheckIdentities,
+}
+
+// identityConfig specifies how to map the Gitee IDs in the OWNERS and sig-info files
+// to the GitLab usernames. The IDs are used as they are if they are not mapped.
+type identityConfig struct {
+	// Repo is the repository holding the mapping file, such as 'openeuler/community'.
+	Repo string `json:"repo,omitempty"`
+
+	// Branch is the branch of Repo. The default value is master.
+	Branch string `json:"branch,omitempty"`
+
+	// Path is the path of mapping file in Repo. The default value is identities.yaml.
+	Path string `json:"path,omitempty"`
+
+	// MatchEmail means a person in the sig-info files is mapped to the GitLab user with the same email
+	// if it is not found in the mapping file.
+	MatchEmail bool `json:"match_email,omitempty"`
+
+	// FallbackToGiteeID means the Gitee ID which is not mapped is used as the GitLab username.
+	// Otherwise, the person has no right, since the user of the same name on GitLab may be someone else.
+	FallbackToGiteeID bool `json:"fallback_to_gitee_id,omitempty"`
+}
+
+func (c *identityConfig) setDefault() {
+	if c.Branch == "" {
+		c.Branch = defaultIdentityBranch
+	}
+
+	if c.Path == "" {
+		c.Path = defaultIdentityFile
+	}
+}
+
+func (c *identityConfig) validate() error {
+	if c.Repo == "" && !c.MatchEmail {
+		return fmt.Errorf("either repo or match_email of identity must be set")
+	}
+
+	return nil
+}
+
+// identityFile is the content of mapping file.
+type identityFile struct {
+	Identities []struct {
+		GiteeID  string `json:"gitee_id"`
+		GitlabID string `json:"gitlab_id"`
+	} `json:"identities,omitempty"`
+}
+
+// identities maps the Gitee IDs to the GitLab usernames. The mapping file is loaded
+// when it is used at the first time. A nil identities maps nothing.
+type identities struct {
+	cli       iClient
+	directory *directoryCache
+	cfg       *identityConfig
+	log       *logrus.Entry
+
+	once   sync.Once
+	logins map[string]string
+}
+
+func loadIdentities(cli iClient, directory *directoryCache, cfg *botConfig, log *logrus.Entry) *identities {
+	if cfg == nil || cfg.Identity == nil {
+		return nil
+	}
+
+	return &identities{cli: cli, directory: directory, cfg: cfg.Identity, log: log}
+}
+
+func (ids *identities) loadFile() {
+	v, err := ids.directory.identitiesOf(ids.cli, ids.cfg)
+	if err != nil {
+		ids.log.WithError(err).Errorf("get the identity file %s of %s", ids.cfg.Path, ids.cfg.Repo)
+	}
+
+	ids.logins = v
+}
+
+// identitiesOf returns the GitLab usernames keyed by the lower case of Gitee ID in the mapping file.
+// The mappings are cached as the items of 'gitee_id gitlab_id'.
+func (c *directoryCache) identitiesOf(cli iClient, cfg *identityConfig) (map[string]string, error) {
+	r := map[string]string{}
+
+	if cfg.Repo == "" {
+		return r, nil
+	}
+
+	v, err := c.get(fmt.Sprintf("identities:%s:%s:%s", cfg.Repo, cfg.Branch, cfg.Path), func() (sets.String, error) {
+		f, err := cli.GetPathContent(cfg.Repo, cfg.Path, cfg.Branch)
+		if err != nil {
+			return nil, err
+		}
+
+		if f == nil {
+			return sets.NewString(), nil
+		}
+
+		c, err := base64.StdEncoding.DecodeString(f.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		var m identityFile
+		if err := yaml.Unmarshal(c, &m); err != nil {
+			return nil, err
+		}
+
+		items := sets.NewString()
+		for _, v := range m.Identities {
+			if v.GiteeID != "" && v.GitlabID != "" {
+				items.Insert(strings.ToLower(v.GiteeID) + " " + v.GitlabID)
+			}
+		}
+
+		return items, nil
+	})
+	if err != nil {
+		return r, err
+	}
+
+	for item := range v {
+		if i := strings.Index(item, " "); i > 0 {
+			r[item[:i]] = item[i+1:]
+		}
+	}
+
+	return r, nil
+}
+
+// lookup returns the GitLab username of the Gitee ID, which is found in the mapping file
+// or by the email. It returns false if it is not mapped.
+func (ids *identities) lookup(giteeID, email string) (string, bool) {
+	if ids == nil {
+		return "", false
+	}
+
+	ids.once.Do(ids.loadFile)
+
+	if v, ok := ids.logins[strings.ToLower(giteeID)]; ok {
+		return v, true
+	}
+
+	if !ids.cfg.MatchEmail || email == "" {
+		return "", false
+	}
+
+	v, err := ids.directory.loginOfEmail(ids.cli, email)
+	if err != nil {
+		ids.log.WithError(err).Errorf("search the user of email %s", email)
+	}
+
+	return v, v != ""
+}
+
+// gitlabLogin returns the GitLab username of the Gitee ID. The ID which is not mapped is used
+// as it is when there is no mapping or the fallback is enabled. Otherwise, it returns empty.
+func (ids *identities) gitlabLogin(giteeID, email string) string {
+	if v, ok := ids.lookup(giteeID, email); ok {
+		return v
+	}
+
+	if ids == nil || ids.cfg.FallbackToGiteeID {
+		return giteeID
+	}
+
+	return ""
+}
+
+// loginOfEmail returns the username of the active user whose email is the specified one.
+// It returns empty if there is no such user.
+func (c *directoryCache) loginOfEmail(cli iClient, email string) (string, error) {
+	v, err := c.get("email:"+email, func() (sets.String, error) {
+		users, err := cli.SearchUsers(email)
+		if err != nil {
+			return nil, err
+		}
+
+		r := sets.NewString()
+		for _, u := range users {
+			if u.State != "blocked" &&
+				(strings.EqualFold(u.Email, email) || strings.EqualFold(u.PublicEmail, email)) {
+				r.Insert(u.Username)
+			}
+		}
+
+		return r, nil
+	})
+
+	if err != nil || v.Len() != 1 {
+		return "", err
+	}
+
+	return v.UnsortedList()[0], nil
+}
+
+// existsUser reports whether there is a GitLab user with the username.
+func (c *directoryCache) existsUser(cli iClient, login string) (bool, error) {
+	v, err := c.get("user:"+login, func() (sets.String, error) {
+		users, err := cli.SearchUsers(login)
+		if err != nil {
+			return nil, err
+		}
+
+		r := sets.NewString()
+		for _, u := range users {
+			if strings.EqualFold(u.Username, login) {
+				r.Insert(u.Username)
+			}
+		}
+
+		return r, nil
+	})
+
+	return v.Len() > 0, err
+}
+
+// botLogin returns the username of the account which the robot acts as.
+func (c *directoryCache) botLogin(cli iClient) (string, error) {
+	v, err := c.get("bot:", func() (sets.String, error) {
+		u, err := cli.GetCurrentUser()
+		if err != nil {
+			return nil, err
+		}
+
+		return sets.NewString(u.Username), nil
+	})
+
+	if err != nil || v.Len() == 0 {
+		return "", err
+	}
+
+	return v.UnsortedList()[0], nil
+}
+
+func (bot *robot) handleCheckIdentities(
+	cmd command, e *gitlab.MergeCommentEvent, cfg *botConfig, log *logrus.Entry,
+) error {
+	if !cmd.hasArgs() {
+		return nil
+	}
+
+	org, repo := gitlabclient.GetMRCommentOrgAndRepo(e)
+	pid := e.ProjectID
+	number := e.MergeRequest.IID
+
+	unmapped, fallbacks := bot.unmappedOwners(cfg, org+"/"+repo, e, log)
+
+	return bot.cli.CreateMergeRequestComment(pid, number, cfg.message(commentUnmappedOwners, messageArgs{
+		"Commenter": gitlabclient.GetMRCommentAuthor(e),
+		"Owners":    strings.Join(unmapped, ", "),
+		"Fallbacks": strings.Join(fallbacks, ", "),
+	}))
+}
+
+// unmappedOwners returns the people in the OWNERS files of the directories changed by PR and
+// their parents, and in the sig-info files of the related sigs, who are not mapped to GitLab users.
+// The ones whose IDs are used as the GitLab usernames by the fallback are returned separately.
+// Without the fallback, all of the people not mapped are returned as unmapped since they have no right.
+func (bot *robot) unmappedOwners(
+	cfg *botConfig, repo string, e *gitlab.MergeCommentEvent, log *logrus.Entry,
+) ([]string, []string) {
+	pid := e.ProjectID
+	number := e.MergeRequest.IID
+
+	ids := loadIdentities(bot.cli, bot.directory, cfg, log)
+
+	// the owners keyed by Gitee ID with their emails
+	owners := map[string]string{}
+
+	resolver := bot.newOwnerResolver(cfg, pid, e.MergeRequest.TargetBranch, log)
+	for _, o := range bot.ownersFilesOfPR(pid, number, e.MergeRequest.TargetBranch, log) {
+		for _, role := range []string{roleApprover, roleReviewer, sigRoleMaintainer, sigRoleCommitter} {
+			for _, v := range resolver.expandAliases(o.peopleOf(role)) {
+				if !strings.HasPrefix(v, ownerGroupPrefix) {
+					owners[v] = ""
+				}
+			}
+		}
+	}
+
+	for _, s := range loadSigsOfPR(bot.cli, cfg, pid, number, e.MergeRequest.TargetBranch, repo, log) {
+		for _, v := range s.persons() {
+			if v.GitlabID == "" && v.GiteeID != "" {
+				owners[v.GiteeID] = v.Email
+			}
+		}
+	}
+
+	unmapped := sets.NewString()
+	fallbacks := sets.NewString()
+	fallback := ids == nil || ids.cfg.FallbackToGiteeID
+
+	for login, email := range owners {
+		if _, ok := ids.lookup(login, email); ok {
+			continue
+		}
+
+		if !fallback {
+			unmapped.Insert(login)
+
+			continue
+		}
+
+		exists, err := bot.directory.existsUser(bot.cli, login)
+		if err != nil {
+			log.WithError(err).Errorf("search the user %s", login)
+		}
+
+		if exists {
+			fallbacks.Insert(login)
+		} else {
+			unmapped.Insert(login)
+		}
+	}
+
+	return unmapped.List(), fallbacks.List()
+}
@@ -1,10 +1,8 @@
 package main
 
 import (
-	"fmt"
 	"github.com/opensourceways/community-robot-lib/gitlabclient"
 	"github.com/xanzy/go-gitlab"
-	"regexp"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -12,39 +10,36 @@ import (
 )
 
 const (
-	// the gitee platform limits the maximum length of label to 20.
-	labelLenLimit = 20
-	lgtmLabel     = "lgtm"
-
-	commentAddLGTMBySelf            = "***lgtm*** can not be added in your self-own pull request. :astonished:"
-	commentClearLabel               = `New code changes of pr are detected and remove these labels ***%s***. :flushed: `
-	commentNoPermissionForLgtmLabel = `Thanks for your review, ***%s***, your opinion is very important to us.:wave:
-The maintainers will consider your advice carefully.`
-	commentNoPermissionForLabel = `
-***@%s*** has no permission to %s ***%s*** label in this pull request. :astonished:
-Please contact to the collaborators in this repository.`
-	commentAddLabel = `***%s*** was added to this pull request by: ***%s***. :wave: 
-**NOTE:** If this pull request is not merged while all conditions are met, comment "/check-pr" to try again. :smile: `
-	commentRemovedLabel = `***%s*** was removed in this pull request by: ***%s***. :flushed: `
+	lgtmLabel = "lgtm"
+	cmdLGTM   = "lgtm"
 )
 
-var (
-	regAddLgtm    = regexp.MustCompile(`(?mi)^/lgtm\s*$`)
-	regRemoveLgtm = regexp.MustCompile(`(?mi)^/lgtm cancel\s*$`)
-)
+var lgtmCommand = commandSpec{
+	names:       []string{cmdLGTM},
+	syntax:      "/lgtm [cancel]",
+	examples:    []string{"/lgtm", "/lgtm cancel"},
+	description: "Add or remove the `lgtm` label for a pull request, it is used to determine whether the pull request can be merged.",
+	whoCanUse: func(cfg *botConfig) string {
+		who := whoHasPermission(cfg, cmdLGTM, true)
+		if len(cfg.LgtmRoles) > 0 {
+			who = whoHasRoles(cfg, cmdLGTM, cfg.LgtmRoles)
+		}
+
+		return who + ". The pull request author can use `/lgtm cancel`, but can not use `/lgtm`."
+	},
+	handle: (*robot).handleLGTM,
+}
 
-func (bot *robot) handleLGTM(e *gitlab.MergeCommentEvent, cfg *botConfig, log *logrus.Entry) error {
-	if e.MergeRequest.State != "opened" || e.ObjectKind != "note" {
+func (bot *robot) handleLGTM(cmd command, e *gitlab.MergeCommentEvent, cfg *botConfig, log *logrus.Entry) error {
+	if e.MergeRequest.State != "opened" {
 		return nil
 	}
 
-	comment := gitlabclient.GetMRCommentBody(e)
-
-	if regAddLgtm.MatchString(comment) {
+	if cmd.hasArgs() {
 		return bot.addLGTM(cfg, e, log)
 	}
 
-	if regRemoveLgtm.MatchString(comment) {
+	if cmd.hasArgs("cancel") {
 		return bot.removeLGTM(cfg, e, log)
 	}
 
@@ -52,7 +47,6 @@ func (bot *robot) handleLGTM(e *gitlab.MergeCommentEvent, cfg *botConfig, log *l
 }
 
 func (bot *robot) addLGTM(cfg *botConfig, e *gitlab.MergeCommentEvent, log *logrus.Entry) error {
-	org, repo := gitlabclient.GetMRCommentOrgAndRepo(e)
 	number := e.MergeRequest.IID
 	pid := e.ProjectID
 	commenterID := gitlabclient.GetMRCommentAuthorID(e)
@@ -60,35 +54,34 @@ func (bot *robot) addLGTM(cfg *botConfig, e *gitlab.MergeCommentEvent, log *logr
 	mrAuthorID := e.MergeRequest.AuthorID
 
 	if mrAuthorID == commenterID {
-		return bot.cli.CreateMergeRequestComment(pid, number, commentAddLGTMBySelf)
+		return bot.cli.CreateMergeRequestComment(pid, number, cfg.message(commentAddLGTMBySelf, nil))
 	}
 
-	v, err := bot.hasPermission(
-		org, repo, commenter, commenterID, cfg.CheckPermissionBasedOnSigOwners, e, cfg, log,
-	)
+	v, eligible, err := bot.checkPermission(cmdLGTM, cfg.LgtmRoles, cfg.CheckPermissionBasedOnSigOwners, e, cfg, log)
 	if err != nil {
 		return err
 	}
 	if !v {
 		return bot.cli.CreateMergeRequestComment(
 			pid, number,
-			fmt.Sprintf(commentNoPermissionForLgtmLabel, commenter),
+			cfg.message(commentNoPermissionForLgtmLabel, messageArgs{
+				"Commenter": commenter,
+				"Eligible":  formatEligible(eligible),
+			}),
 		)
 	}
 
-	label := genLGTMLabel(commenter, cfg.LgtmCountsRequired)
-	if label != lgtmLabel {
-		if err := bot.createLabelIfNeed(pid, label); err != nil {
-			log.WithError(err).Errorf("create repo label: %s", label)
+	if cfg.LgtmCountsRequired > 1 {
+		added, err := bot.recordLGTM(cfg, e, commenter, true)
+		if err != nil || !added {
+			return err
 		}
-	}
-
-	if err := bot.cli.AddMergeRequestLabel(pid, number, []string{label}); err != nil {
+	} else if err := bot.cli.AddMergeRequestLabel(pid, number, []string{lgtmLabel}); err != nil {
 		return err
 	}
 
-	err = bot.cli.CreateMergeRequestComment(
-		pid, number, fmt.Sprintf(commentAddLabel, label, commenter),
+	err = bot.postActionComment(
+		cfg, pid, number, cfg.message(commentAddLabel, messageArgs{"Label": lgtmLabel, "Commenter": commenter}),
 	)
 	if err != nil {
 		log.Error(err)
@@ -97,8 +90,30 @@ func (bot *robot) addLGTM(cfg *botConfig, e *gitlab.MergeCommentEvent, log *logr
 	return bot.tryMerge(e, cfg, false, log)
 }
 
+// recordLGTM adds or removes the lgtm of user in the lgtm records of PR.
+// It returns false if the records are not changed.
+func (bot *robot) recordLGTM(cfg *botConfig, e *gitlab.MergeCommentEvent, user string, add bool) (bool, error) {
+	user = strings.ToLower(user)
+
+	return bot.updateLGTMRecords(
+		cfg, e.ProjectID, e.MergeRequest.IID, e.MergeRequest.AuthorID,
+		func(r *lgtmRecords, _ sets.String) bool {
+			if r.users.Has(user) == add && len(r.legacy) == 0 {
+				return false
+			}
+
+			if add {
+				r.users.Insert(user)
+			} else {
+				r.users.Delete(user)
+			}
+
+			return true
+		},
+	)
+}
+
 func (bot *robot) removeLGTM(cfg *botConfig, e *gitlab.MergeCommentEvent, log *logrus.Entry) error {
-	org, repo := gitlabclient.GetMRCommentOrgAndRepo(e)
 	number := e.MergeRequest.IID
 	pid := e.ProjectID
 	commenterID := gitlabclient.GetMRCommentAuthorID(e)
@@ -106,37 +121,52 @@ func (bot *robot) removeLGTM(cfg *botConfig, e *gitlab.MergeCommentEvent, log *l
 	mrAuthorID := e.MergeRequest.AuthorID
 
 	if mrAuthorID != commenterID {
-		v, err := bot.hasPermission(
-			org, repo, commenter, commenterID, cfg.CheckPermissionBasedOnSigOwners, e, cfg, log,
-		)
+		v, eligible, err := bot.checkPermission(cmdLGTM, cfg.LgtmRoles, cfg.CheckPermissionBasedOnSigOwners, e, cfg, log)
 		if err != nil {
 			return err
 		}
 		if !v {
-			return bot.cli.CreateMergeRequestComment(pid, number, fmt.Sprintf(
-				commentNoPermissionForLabel, commenter, "remove", lgtmLabel,
+			return bot.cli.CreateMergeRequestComment(pid, number, cfg.message(
+				commentNoPermissionForLabel,
+				messageArgs{
+					"Commenter": commenter,
+					"Action":    "remove",
+					"Label":     lgtmLabel,
+					"Eligible":  formatEligible(eligible),
+				},
 			))
 		}
 
-		l := genLGTMLabel(commenter, cfg.LgtmCountsRequired)
-		if err = bot.cli.RemoveMergeRequestLabel(pid, number, []string{l}); err != nil {
+		if cfg.LgtmCountsRequired > 1 {
+			removed, err := bot.recordLGTM(cfg, e, commenter, false)
+			if err != nil || !removed {
+				return err
+			}
+		} else if err = bot.cli.RemoveMergeRequestLabel(pid, number, []string{lgtmLabel}); err != nil {
 			return err
 		}
 
-		return bot.cli.CreateMergeRequestComment(
-			pid, number, fmt.Sprintf(commentRemovedLabel, l, commenter),
+		return bot.postActionComment(
+			cfg, pid, number, cfg.message(commentRemovedLabel, messageArgs{"Label": lgtmLabel, "Commenter": commenter}),
 		)
 	}
 
-	// the author of pr can remove all of lgtm[-login name] kind labels
+	// the author of pr can remove all of lgtm
 	lbs := sets.NewString()
 	mrLabels, err := bot.cli.GetMergeRequestLabels(pid, number)
 	if err != nil {
 		return err
 	}
 	lbs.Insert(mrLabels...)
-	if v := getLGTMLabelsOnPR(lbs); len(v) > 0 {
-		return bot.cli.RemoveMergeRequestLabel(pid, number, v)
+
+	if cfg.LgtmCountsRequired > 1 {
+		_, err := bot.clearLGTMRecords(cfg, pid, number, mrAuthorID)
+
+		return err
+	}
+
+	if lbs.Has(lgtmLabel) {
+		return bot.cli.RemoveMergeRequestLabel(pid, number, []string{lgtmLabel})
 	}
 
 	return nil
@@ -157,24 +187,12 @@ func (bot *robot) createLabelIfNeed(pid int, label string) error {
 	return bot.cli.CreateProjectLabel(pid, label, "")
 }
 
-func genLGTMLabel(commenter string, lgtmCount uint) string {
-	if lgtmCount <= 1 {
-		return lgtmLabel
-	}
-
-	l := fmt.Sprintf("%s-%s", lgtmLabel, strings.ToLower(commenter))
-	if len(l) > labelLenLimit {
-		return l[:labelLenLimit]
-	}
-
-	return l
-}
-
+// getLGTMLabelsOnPR returns the lgtm label and the summary label showing the number of lgtm.
 func getLGTMLabelsOnPR(labels sets.String) []string {
 	var r []string
 
 	for l := range labels {
-		if strings.HasPrefix(l, lgtmLabel) {
+		if l == lgtmLabel || isLGTMCountLabel(l) {
 			r = append(r, l)
 		}
 	}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// lgtmCountLabelPrefix is the prefix of the summary label which shows the number of
+	// lgtm when more than one lgtm is required, such as lgtm/2.
+	lgtmCountLabelPrefix = lgtmLabel + "/"
+
+	// legacyLGTMLabelPrefix is the prefix of the labels of 'lgtm-<login>' kind used before.
+	// Their names were truncated, so that they are migrated to the lgtm records.
+	legacyLGTMLabelPrefix = lgtmLabel + "-"
+	legacyLGTMLabelLimit  = 20
+
+	lgtmRecordsMarker = "<!-- lgtm-records -->"
+)
+
+var regLGTMRecords = regexp.MustCompile(`<!-- lgtm-records-data: (.*?) -->`)
+
+// lgtmRecords are the users who have given lgtm to PR when more than one lgtm is required.
+// They are stored in a comment of robot, so that counting lgtm does not depend on the names
+// of labels. The summary label lgtm/<count> is kept in sync with them.
+type lgtmRecords struct {
+	users  sets.String
+	noteID int
+
+	// legacy is the labels of 'lgtm-<login>' kind which the records were migrated from.
+	legacy []string
+}
+
+func isLGTMCountLabel(label string) bool {
+	if !strings.HasPrefix(label, lgtmCountLabelPrefix) {
+		return false
+	}
+
+	_, err := strconv.ParseUint(strings.TrimPrefix(label, lgtmCountLabelPrefix), 10, 32)
+
+	return err == nil
+}
+
+func genLGTMCountLabel(n int) string {
+	return fmt.Sprintf("%s%d", lgtmCountLabelPrefix, n)
+}
+
+// lgtmCountOnPR returns the number of lgtm shown by the labels of PR.
+func lgtmCountOnPR(labels sets.String, cfg *botConfig) uint {
+	if cfg.LgtmCountsRequired <= 1 {
+		if labels.Has(lgtmLabel) {
+			return 1
+		}
+
+		return 0
+	}
+
+	n := uint64(0)
+	for l := range labels {
+		if !isLGTMCountLabel(l) {
+			continue
+		}
+
+		if v, _ := strconv.ParseUint(strings.TrimPrefix(l, lgtmCountLabelPrefix), 10, 32); v > n {
+			n = v
+		}
+	}
+
+	return uint(n)
+}
+
+func genLegacyLGTMLabel(login string) string {
+	l := legacyLGTMLabelPrefix + strings.ToLower(login)
+	if len(l) > legacyLGTMLabelLimit {
+		return l[:legacyLGTMLabelLimit]
+	}
+
+	return l
+}
+
+// loadLGTMRecords loads the lgtm records of PR. If there are no records, they are migrated
+// from the legacy labels of PR by matching them with the users who have commented /lgtm.
+func (bot *robot) loadLGTMRecords(pid, mrID, authorID int, labels sets.String) (lgtmRecords, error) {
+	notes, err := bot.cli.ListMergeRequestComments(pid, mrID)
+	if err != nil {
+		return lgtmRecords{}, err
+	}
+
+	login, err := bot.directory.botLogin(bot.cli)
+	if err != nil {
+		return lgtmRecords{}, err
+	}
+
+	if r, ok, err := findLGTMRecords(notes, login); ok || err != nil {
+		return r, err
+	}
+
+	r := lgtmRecords{users: sets.NewString()}
+
+	legacy := sets.NewString()
+	for l := range labels {
+		if strings.HasPrefix(l, legacyLGTMLabelPrefix) {
+			legacy.Insert(l)
+		}
+	}
+
+	if legacy.Len() == 0 {
+		return r, nil
+	}
+
+	for u := range newReviewState(notes, authorID).lgtm {
+		u = strings.ToLower(u)
+		if l := genLegacyLGTMLabel(u); legacy.Has(l) {
+			r.users.Insert(u)
+			r.legacy = append(r.legacy, l)
+		}
+	}
+
+	return r, nil
+}
+
+// findLGTMRecords finds the lgtm records in the comments of PR written by the robot whose username is bot.
+func findLGTMRecords(notes []*gitlab.Note, bot string) (lgtmRecords, bool, error) {
+	for _, n := range notes {
+		if n.System || !strings.EqualFold(n.Author.Username, bot) || !strings.Contains(n.Body, lgtmRecordsMarker) {
+			continue
+		}
+
+		r := lgtmRecords{users: sets.NewString(), noteID: n.ID}
+
+		if m := regLGTMRecords.FindStringSubmatch(n.Body); len(m) == 2 {
+			var users []string
+			if err := json.Unmarshal([]byte(m[1]), &users); err != nil {
+				return r, true, fmt.Errorf("parse lgtm records, err:%s", err.Error())
+			}
+
+			r.users.Insert(users...)
+		}
+
+		return r, true, nil
+	}
+
+	return lgtmRecords{}, false, nil
+}
+
+// saveLGTMRecords stores the records and replaces the lgtm labels of PR with the summary label.
+func (bot *robot) saveLGTMRecords(cfg *botConfig, pid, mrID int, r *lgtmRecords, labels sets.String) error {
+	data, err := json.Marshal(r.users.List())
+	if err != nil {
+		return err
+	}
+
+	reviewers := ""
+	if r.users.Len() > 0 {
+		reviewers = "@" + strings.Join(r.users.List(), ", @")
+	}
+
+	body := fmt.Sprintf(
+		"%s\n%s\n<!-- lgtm-records-data: %s -->",
+		lgtmRecordsMarker,
+		cfg.message(commentLGTMRecords, messageArgs{
+			"Count":     r.users.Len(),
+			"Required":  cfg.LgtmCountsRequired,
+			"Reviewers": reviewers,
+		}),
+		data,
+	)
+
+	if r.noteID == 0 {
+		err = bot.cli.CreateMergeRequestComment(pid, mrID, body)
+	} else {
+		err = bot.cli.UpdateMergeRequestComment(pid, mrID, r.noteID, body)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var remove []string
+	for l := range labels {
+		if l == lgtmLabel || isLGTMCountLabel(l) {
+			remove = append(remove, l)
+		}
+	}
+
+	remove = append(remove, r.legacy...)
+
+	want := ""
+	if r.users.Len() > 0 {
+		want = genLGTMCountLabel(r.users.Len())
+	}
+
+	if want != "" && labels.Has(want) {
+		remove = sets.NewString(remove...).Delete(want).List()
+		want = ""
+	}
+
+	if len(remove) > 0 {
+		if err := bot.cli.RemoveMergeRequestLabel(pid, mrID, remove); err != nil {
+			return err
+		}
+	}
+
+	if want == "" {
+		return nil
+	}
+
+	if err := bot.createLabelIfNeed(pid, want); err != nil {
+		return err
+	}
+
+	return bot.cli.AddMergeRequestLabel(pid, mrID, gitlab.Labels{want})
+}
+
+// updateLGTMRecords loads the lgtm records of PR with its current labels, changes them by
+// update and saves them if they are changed. The comments of PR may be handled concurrently,
+// so the records of PR are updated one by one. It returns false if they are not changed.
+func (bot *robot) updateLGTMRecords(
+	cfg *botConfig, pid, mrID, authorID int, update func(r *lgtmRecords, labels sets.String) bool,
+) (bool, error) {
+	defer bot.lgtmLocks.acquire(pid, mrID)()
+
+	mrLabels, err := bot.cli.GetMergeRequestLabels(pid, mrID)
+	if err != nil {
+		return false, err
+	}
+
+	labels := sets.NewString(mrLabels...)
+
+	r, err := bot.loadLGTMRecords(pid, mrID, authorID, labels)
+	if err != nil || !update(&r, labels) {
+		return false, err
+	}
+
+	return true, bot.saveLGTMRecords(cfg, pid, mrID, &r, labels)
+}
+
+// clearLGTMRecords removes all of lgtm of PR. It returns false if there is nothing to remove.
+func (bot *robot) clearLGTMRecords(cfg *botConfig, pid, mrID, authorID int) (bool, error) {
+	return bot.updateLGTMRecords(cfg, pid, mrID, authorID, func(r *lgtmRecords, labels sets.String) bool {
+		if r.users.Len() == 0 && len(r.legacy) == 0 && len(getLGTMLabelsOnPR(labels)) == 0 {
+			return false
+		}
+
+		r.users = sets.NewString()
+
+		return true
+	})
+}
+
+// revokeLGTMRecords removes the lgtm records which are not backed by any comment.
+// It returns the revoked ones, such as 'lgtm(@alice)'.
+func (bot *robot) revokeLGTMRecords(cfg *botConfig, pid, mrID, authorID int, state reviewState) ([]string, error) {
+	active := sets.NewString()
+	for u := range state.lgtm {
+		active.Insert(strings.ToLower(u))
+	}
+
+	var revoked []string
+
+	_, err := bot.updateLGTMRecords(cfg, pid, mrID, authorID, func(r *lgtmRecords, _ sets.String) bool {
+		for _, u := range r.users.Difference(active).List() {
+			r.users.Delete(u)
+			revoked = append(revoked, fmt.Sprintf("%s(@%s)", lgtmLabel, u))
+		}
+
+		return len(revoked) > 0
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return revoked, nil
+}
+
+// migrateLGTMLabels replaces the legacy lgtm labels of PR with the lgtm records.
+// It returns true if the labels of PR are changed.
+func (bot *robot) migrateLGTMLabels(cfg *botConfig, pid int, mr *gitlab.MergeRequest, log *logrus.Entry) (bool, error) {
+	if cfg.LgtmCountsRequired <= 1 {
+		return false, nil
+	}
+
+	return bot.updateLGTMRecords(cfg, pid, mr.IID, mr.Author.ID, func(r *lgtmRecords, _ sets.String) bool {
+		if r.noteID != 0 || len(r.legacy) == 0 {
+			return false
+		}
+
+		log.Infof("migrate the lgtm labels of PR:%d: %s", mr.IID, strings.Join(r.legacy, ", "))
+
+		return true
+	})
+}
+
+// prLocks serializes the changes of each PR, which are keyed by the project and number of PR.
+type prLocks struct {
+	lock  sync.Mutex
+	locks map[string]*prLock
+}
+
+type prLock struct {
+	sync.Mutex
+
+	// refs is the number of callers holding or waiting for the lock.
+	refs int
+}
+
+func newPRLocks() *prLocks {
+	return &prLocks{locks: make(map[string]*prLock)}
+}
+
+// acquire locks PR and returns the function to unlock it.
+func (l *prLocks) acquire(pid, mrID int) func() {
+	if l == nil {
+		return func() {}
+	}
+
+	k := rebaseKey(pid, mrID)
+
+	l.lock.Lock()
+	v, ok := l.locks[k]
+	if !ok {
+		v = new(prLock)
+		l.locks[k] = v
+	}
+	v.refs++
+	l.lock.Unlock()
+
+	v.Lock()
+
+	return func() {
+		v.Unlock()
+
+		l.lock.Lock()
+		if v.refs--; v.refs == 0 {
+			delete(l.locks, k)
+		}
+		l.lock.Unlock()
+	}
+}
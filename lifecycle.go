@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/opensourceways/community-robot-lib/gitlabclient"
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	lifecycleStaleLabel  = "lifecycle/stale"
+	lifecycleFrozenLabel = "lifecycle/frozen"
+	cmdRemoveLifecycle   = "remove-lifecycle"
+	stateEventClose      = "close"
+
+	day = 24 * time.Hour
+)
+
+var removeLifecycleCommand = commandSpec{
+	names:       []string{cmdRemoveLifecycle},
+	syntax:      "/remove-lifecycle stale",
+	examples:    []string{"/remove-lifecycle stale"},
+	description: "Remove the `lifecycle/stale` label, so that the pull request will not be closed as stale.",
+	whoCanUse: func(cfg *botConfig) string {
+		return whoAnyone + "."
+	},
+	handle: (*robot).handleRemoveLifecycle,
+}
+
+// lifecycleConfig specifies how to deal with the PRs which have no activity for a long time.
+type lifecycleConfig struct {
+	// DaysUntilStale is the days without activity after which PR is labeled as lifecycle/stale.
+	DaysUntilStale int `json:"days_until_stale"`
+
+	// DaysUntilClose is the days without activity after which a stale PR is closed.
+	// The stale PR will not be closed if it is 0.
+	DaysUntilClose int `json:"days_until_close,omitempty"`
+}
+
+func (c *lifecycleConfig) validate() error {
+	if c.DaysUntilStale <= 0 {
+		return fmt.Errorf("days_until_stale of lifecycle must be positive")
+	}
+
+	if c.DaysUntilClose < 0 {
+		return fmt.Errorf("days_until_close of lifecycle must not be negative")
+	}
+
+	return nil
+}
+
+// handleLifecycle labels PR as stale or closes it when it has no activity for a long time.
+// PR labeled as lifecycle/frozen or waiting for its reviewers is skipped. The activities of
+// the robot, such as its reminders, do not count. Labeling PR as stale is an activity itself,
+// so a stale PR is closed only if there is no activity since then. It returns true if PR is closed.
+func (bot *robot) handleLifecycle(cfg *botConfig, pid int, mr *gitlab.MergeRequest, log *logrus.Entry) (bool, error) {
+	policy := cfg.Lifecycle
+	if policy == nil || mr.State != gitlabclient.ActionOpened || mr.CreatedAt == nil {
+		return false, nil
+	}
+
+	labels := sets.NewString(mr.Labels...)
+	if labels.Has(lifecycleFrozenLabel) {
+		return false, nil
+	}
+
+	notes, err := bot.cli.ListMergeRequestComments(pid, mr.IID)
+	if err != nil {
+		return false, err
+	}
+
+	if hasPendingReviewers(mr, notes) {
+		return false, nil
+	}
+
+	ops, err := bot.cli.GetMergeRequestLabelChanges(pid, mr.IID)
+	if err != nil {
+		return false, err
+	}
+
+	login, err := bot.directory.botLogin(bot.cli)
+	if err != nil {
+		return false, err
+	}
+
+	idle := time.Since(lastActivity(mr, notes, ops, login))
+
+	if !labels.Has(lifecycleStaleLabel) {
+		if idle < time.Duration(policy.DaysUntilStale)*day {
+			return false, nil
+		}
+
+		log.Infof("PR:%d has no activity for %s, mark it as stale", mr.IID, idle)
+
+		if err := bot.createLabelIfNeed(pid, lifecycleStaleLabel); err != nil {
+			return false, err
+		}
+
+		if err := bot.cli.AddMergeRequestLabel(pid, mr.IID, gitlab.Labels{lifecycleStaleLabel}); err != nil {
+			return false, err
+		}
+
+		return false, bot.cli.CreateMergeRequestComment(pid, mr.IID, cfg.message(commentMarkedStale, messageArgs{
+			"Days":      policy.DaysUntilStale,
+			"CloseDays": policy.DaysUntilClose,
+		}))
+	}
+
+	if policy.DaysUntilClose == 0 {
+		return false, nil
+	}
+
+	if added, ok := getLatestLog(ops, lifecycleStaleLabel, log); ok && time.Since(added.t) < idle {
+		idle = time.Since(added.t)
+	}
+
+	if idle < time.Duration(policy.DaysUntilClose)*day {
+		return false, nil
+	}
+
+	log.Infof("stale PR:%d has no activity for %s, close it", mr.IID, idle)
+
+	if err := bot.cli.CreateMergeRequestComment(
+		pid, mr.IID, cfg.message(commentClosedStale, messageArgs{"Days": policy.DaysUntilClose}),
+	); err != nil {
+		return false, err
+	}
+
+	event := stateEventClose
+	if _, err := bot.cli.UpdateMergeRequest(pid, mr.IID, gitlab.UpdateMergeRequestOptions{StateEvent: &event}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// hasPendingReviewers reports whether any reviewer assigned to PR has not commented on it yet.
+func hasPendingReviewers(mr *gitlab.MergeRequest, notes []*gitlab.Note) bool {
+	responded := sets.NewString()
+	for _, n := range notes {
+		if !n.System {
+			responded.Insert(strings.ToLower(n.Author.Username))
+		}
+	}
+
+	for _, v := range mr.Reviewers {
+		if !responded.Has(strings.ToLower(v.Username)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lastActivity returns the time of the latest activity on PR, such as comments, pushes and
+// changes of labels. The activities of the robot do not mean that PR is still active.
+func lastActivity(mr *gitlab.MergeRequest, notes []*gitlab.Note, ops []*gitlab.LabelEvent, bot string) time.Time {
+	t := *mr.CreatedAt
+
+	update := func(who string, at *time.Time) {
+		if at != nil && at.After(t) && !strings.EqualFold(who, bot) {
+			t = *at
+		}
+	}
+
+	for _, n := range notes {
+		update(n.Author.Username, commandTime(n))
+	}
+
+	for _, op := range ops {
+		update(op.User.Username, op.CreatedAt)
+	}
+
+	return t
+}
+
+func (bot *robot) handleRemoveLifecycle(cmd command, e *gitlab.MergeCommentEvent, cfg *botConfig, log *logrus.Entry) error {
+	if e.MergeRequest.State != gitlabclient.ActionOpened || !cmd.hasArgs("stale") {
+		return nil
+	}
+
+	pid := e.ProjectID
+	number := e.MergeRequest.IID
+
+	labels, err := bot.cli.GetMergeRequestLabels(pid, number)
+	if err != nil {
+		return err
+	}
+
+	if !sets.NewString(labels...).Has(lifecycleStaleLabel) {
+		return nil
+	}
+
+	if err := bot.cli.RemoveMergeRequestLabel(pid, number, gitlab.Labels{lifecycleStaleLabel}); err != nil {
+		return err
+	}
+
+	return bot.postActionComment(cfg, pid, number, cfg.message(commentRemovedLabel, messageArgs{
+		"Label":     lifecycleStaleLabel,
+		"Commenter": gitlabclient.GetMRCommentAuthor(e),
+	}))
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/xanzy/go-gitlab"
+)
+
+var errLimiterStopped = errors.New("the limiter of gitlab api is stopped")
+
+// limitedClient limits the rate of calling gitlab api, so that the reconciler does not
+// exceed its quota however many calls an evaluation of PR makes. wait blocks until the
+// next call is allowed, and it returns false when the limiter is stopped.
+type limitedClient struct {
+	iClient
+
+	allow func() bool
+}
+
+func (c *limitedClient) wait() error {
+	if !c.allow() {
+		return errLimiterStopped
+	}
+
+	return nil
+}
+
+func (c *limitedClient) GetMergeRequestLabels(projectID interface{}, mrID int) (gitlab.Labels, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.GetMergeRequestLabels(projectID, mrID)
+}
+
+func (c *limitedClient) CreateMergeRequestComment(projectID interface{}, mrID int, comment string) error {
+	if err := c.wait(); err != nil {
+		return err
+	}
+
+	return c.iClient.CreateMergeRequestComment(projectID, mrID, comment)
+}
+
+func (c *limitedClient) RemoveMergeRequestLabel(projectID interface{}, mrID int, labels gitlab.Labels) error {
+	if err := c.wait(); err != nil {
+		return err
+	}
+
+	return c.iClient.RemoveMergeRequestLabel(projectID, mrID, labels)
+}
+
+func (c *limitedClient) GetProjectLabels(projectID interface{}) ([]*gitlab.Label, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.GetProjectLabels(projectID)
+}
+
+func (c *limitedClient) CreateProjectLabel(pid interface{}, label, color string) error {
+	if err := c.wait(); err != nil {
+		return err
+	}
+
+	return c.iClient.CreateProjectLabel(pid, label, color)
+}
+
+func (c *limitedClient) UpdateMergeRequestComment(projectID interface{}, mrID, noteID int, comment string) error {
+	if err := c.wait(); err != nil {
+		return err
+	}
+
+	return c.iClient.UpdateMergeRequestComment(projectID, mrID, noteID, comment)
+}
+
+func (c *limitedClient) AddMergeRequestLabel(projectID interface{}, mrID int, labels gitlab.Labels) error {
+	if err := c.wait(); err != nil {
+		return err
+	}
+
+	return c.iClient.AddMergeRequestLabel(projectID, mrID, labels)
+}
+
+func (c *limitedClient) GetUserAccessLevelOfProject(projectID interface{}, userID int) (gitlab.AccessLevelValue, error) {
+	if err := c.wait(); err != nil {
+		return 0, err
+	}
+
+	return c.iClient.GetUserAccessLevelOfProject(projectID, userID)
+}
+
+func (c *limitedClient) GetMergeRequestChanges(projectID interface{}, mrID int) ([]string, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.GetMergeRequestChanges(projectID, mrID)
+}
+
+func (c *limitedClient) AcceptMergeRequest(projectID interface{}, mrID int, opts gitlab.AcceptMergeRequestOptions) (*gitlab.MergeRequest, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.AcceptMergeRequest(projectID, mrID, opts)
+}
+
+func (c *limitedClient) ListMergeRequestComments(projectID interface{}, mrID int) ([]*gitlab.Note, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.ListMergeRequestComments(projectID, mrID)
+}
+
+func (c *limitedClient) GetMergeRequestLabelChanges(projectID interface{}, mrID int) ([]*gitlab.LabelEvent, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.GetMergeRequestLabelChanges(projectID, mrID)
+}
+
+func (c *limitedClient) GetMergeRequest(projectID interface{}, mrID int) (gitlab.MergeRequest, error) {
+	if err := c.wait(); err != nil {
+		return gitlab.MergeRequest{}, err
+	}
+
+	return c.iClient.GetMergeRequest(projectID, mrID)
+}
+
+func (c *limitedClient) GetMergeRequestWithOptions(projectID interface{}, mrID int, opts gitlab.GetMergeRequestsOptions) (gitlab.MergeRequest, error) {
+	if err := c.wait(); err != nil {
+		return gitlab.MergeRequest{}, err
+	}
+
+	return c.iClient.GetMergeRequestWithOptions(projectID, mrID, opts)
+}
+
+func (c *limitedClient) UpdateMergeRequest(projectID interface{}, mrID int, options gitlab.UpdateMergeRequestOptions) (gitlab.MergeRequest, error) {
+	if err := c.wait(); err != nil {
+		return gitlab.MergeRequest{}, err
+	}
+
+	return c.iClient.UpdateMergeRequest(projectID, mrID, options)
+}
+
+func (c *limitedClient) GetPathContent(projectID interface{}, file, branch string) (*gitlab.File, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.GetPathContent(projectID, file, branch)
+}
+
+func (c *limitedClient) GetDirectoryTree(projectID interface{}, opts gitlab.ListTreeOptions) ([]*gitlab.TreeNode, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.GetDirectoryTree(projectID, opts)
+}
+
+func (c *limitedClient) GetGroups() ([]*gitlab.Group, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.GetGroups()
+}
+
+func (c *limitedClient) GetProjects(gid interface{}) ([]*gitlab.Project, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.GetProjects(gid)
+}
+
+func (c *limitedClient) GetGroupMembers(gid interface{}) ([]*gitlab.GroupMember, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.GetGroupMembers(gid)
+}
+
+func (c *limitedClient) SearchUsers(search string) ([]*gitlab.User, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.SearchUsers(search)
+}
+
+func (c *limitedClient) GetCurrentUser() (*gitlab.User, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.GetCurrentUser()
+}
+
+func (c *limitedClient) GetProject(projectID interface{}) (*gitlab.Project, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.GetProject(projectID)
+}
+
+func (c *limitedClient) CreateIssue(projectID interface{}, opts gitlab.CreateIssueOptions) (*gitlab.Issue, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.CreateIssue(projectID, opts)
+}
+
+func (c *limitedClient) ListProjectIssues(projectID interface{}, opts gitlab.ListProjectIssuesOptions) ([]*gitlab.Issue, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.ListProjectIssues(projectID, opts)
+}
+
+func (c *limitedClient) UpdateIssue(projectID interface{}, issueID int, opts gitlab.UpdateIssueOptions) (*gitlab.Issue, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.UpdateIssue(projectID, issueID, opts)
+}
+
+func (c *limitedClient) SetCommitStatus(projectID interface{}, sha string, opts gitlab.SetCommitStatusOptions) error {
+	if err := c.wait(); err != nil {
+		return err
+	}
+
+	return c.iClient.SetCommitStatus(projectID, sha, opts)
+}
+
+func (c *limitedClient) RebaseMergeRequest(projectID interface{}, mrID int) error {
+	if err := c.wait(); err != nil {
+		return err
+	}
+
+	return c.iClient.RebaseMergeRequest(projectID, mrID)
+}
+
+func (c *limitedClient) ListProjectMergeRequests(projectID interface{}, opts gitlab.ListProjectMergeRequestsOptions) ([]*gitlab.MergeRequest, error) {
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	return c.iClient.ListProjectMergeRequests(projectID, opts)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/opensourceways/community-robot-lib/gitlabclient"
 	"net/url"
 	"os"
+	"time"
 
 	"github.com/opensourceways/community-robot-lib/logrusutil"
 	liboptions "github.com/opensourceways/community-robot-lib/options"
@@ -21,6 +22,9 @@ type options struct {
 	gitlab        liboptions.GitLabOptions
 	cacheEndpoint string
 	maxRetries    int
+
+	reconcileInterval time.Duration
+	reconcileQPS      float64
 }
 
 func (o *options) Validate() error {
@@ -28,6 +32,10 @@ func (o *options) Validate() error {
 		return err
 	}
 
+	if o.reconcileInterval > 0 && o.reconcileQPS <= 0 {
+		return errors.New("reconcile-qps must be positive when the reconciler is enabled")
+	}
+
 	if err := o.service.Validate(); err != nil {
 		return err
 	}
@@ -42,6 +50,11 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	o.service.AddFlags(fs)
 	fs.StringVar(&o.cacheEndpoint, "cache-endpoint", "", "The endpoint of repo file cache")
 	fs.IntVar(&o.maxRetries, "max-retries", 3, "The number of failed retry attempts to call the cache api")
+	fs.DurationVar(
+		&o.reconcileInterval, "reconcile-interval", 0,
+		"The interval to re-evaluate the open merge requests. The reconciler is disabled if it is 0",
+	)
+	fs.Float64Var(&o.reconcileQPS, "reconcile-qps", 2, "The max number of gitlab api calls per second made by the reconciler")
 
 	_ = fs.Parse(args)
 
@@ -84,5 +97,11 @@ func main() {
 		return nil, errors.New("can't convert to configuration")
 	})
 
+	if o.reconcileInterval > 0 {
+		r.startReconciler(o.reconcileInterval, o.reconcileQPS)
+	}
+
 	framework.Run(r, o.service.Port, o.service.GracePeriod)
+
+	r.stop()
 }
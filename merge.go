@@ -2,10 +2,11 @@ package main
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"github.com/opensourceways/community-robot-lib/gitlabclient"
 	"github.com/xanzy/go-gitlab"
-	"regexp"
+	"net/http"
 	"strings"
 	"time"
 
@@ -15,26 +16,31 @@ import (
 )
 
 const (
-	msgPRConflicts        = "PR conflicts to the target branch."
-	msgMissingLabels      = "PR does not have these lables: %s"
-	msgInvalidLabels      = "PR should remove these labels: %s"
-	msgNotEnoughLGTMLabel = "PR needs %d lgtm labels and now gets %d"
-	msgFrozenWithOwner    = "The target branch of PR has been frozen and it can be merge only by branch owners: %s"
-	//legalLabelsAddedBy    = "openeuler-ci-bot"
-	legalLabelsAddedBy = "wanghao"
-	canMergeStatus     = "can_be_merged"
-	ActionAddLabel     = "add"
+	canMergeStatus = "can_be_merged"
+	ActionAddLabel = "add"
 )
 
-var regCheckPr = regexp.MustCompile(`(?mi)^/check-pr\s*$`)
+var checkPRCommand = commandSpec{
+	names:       []string{"check-pr"},
+	syntax:      "/check-pr",
+	examples:    []string{"/check-pr"},
+	description: "Check whether the pull request meets the merge conditions and merge it if it does, otherwise list the reasons.",
+	whoCanUse: func(cfg *botConfig) string {
+		return whoAnyone + "."
+	},
+	handle: (*robot).handleCheckPR,
+}
 
-func (bot *robot) handleCheckPR(e *gitlab.MergeCommentEvent, cfg *botConfig, log *logrus.Entry) error {
-	if e.MergeRequest.State != gitlabclient.ActionOpened ||
-		e.ObjectKind != "note" ||
-		!regCheckPr.MatchString(gitlabclient.GetMRCommentBody(e)) {
+func (bot *robot) handleCheckPR(cmd command, e *gitlab.MergeCommentEvent, cfg *botConfig, log *logrus.Entry) error {
+	if e.MergeRequest.State != gitlabclient.ActionOpened || !cmd.hasArgs() {
 		return nil
 	}
 
+	// the comments may have been edited or deleted since the labels were added.
+	if _, _, err := bot.reconcileReviewLabels(cfg, e.ProjectID, e.MergeRequest.IID, e.MergeRequest.AuthorID, log); err != nil {
+		log.WithError(err).Error("reconcile review labels")
+	}
+
 	return bot.tryMerge(e, cfg, true, log)
 }
 
@@ -44,37 +50,39 @@ func (bot *robot) tryMerge(e *gitlab.MergeCommentEvent, cfg *botConfig, addComme
 	commenter := gitlabclient.GetMRCommentAuthor(e)
 	org, _ := gitlabclient.GetMRCommentOrgAndRepo(e)
 
-	mergeRequest, err := bot.cli.GetMergeRequest(pid, number)
+	mergeRequest, err := getMergeRequest(bot.cli, pid, number)
 	if err != nil {
 		return err
 	}
 
 	h := mergeHelper{
-		cfg:     cfg,
-		pid:     pid,
-		mrID:    number,
-		org:     org,
-		author:  mergeRequest.Author.Username,
-		cli:     bot.cli,
-		mr:      &mergeRequest,
-		trigger: gitlabclient.GetMRCommentAuthor(e),
+		cfg:       cfg,
+		pid:       pid,
+		mrID:      number,
+		org:       org,
+		author:    mergeRequest.Author.Username,
+		cli:       bot.cli,
+		directory: bot.directory,
+		rebases:   bot.rebases,
+		mr:        &mergeRequest,
+		trigger:   gitlabclient.GetMRCommentAuthor(e),
 	}
 
 	if r, ok := h.canMerge(log); !ok {
 		if len(r) > 0 && addComment {
-			return bot.cli.CreateMergeRequestComment(
-				pid, number,
-				fmt.Sprintf(
-					"@%s , this pr is not mergeable and the reasons are below:\n%s",
-					commenter, strings.Join(r, "\n"),
-				),
+			return bot.postActionComment(
+				cfg, pid, number,
+				cfg.message(commentNotMergeable, messageArgs{
+					"Commenter": commenter,
+					"Reasons":   strings.Join(r, "\n"),
+				}),
 			)
 		}
 
 		return nil
 	}
 
-	return h.merge()
+	return bot.doMerge(&h, log)
 }
 
 func (bot *robot) handleLabelUpdate(e *gitlab.MergeEvent, cfg *botConfig, log *logrus.Entry) error {
@@ -83,21 +91,25 @@ func (bot *robot) handleLabelUpdate(e *gitlab.MergeEvent, cfg *botConfig, log *l
 	}
 	org, _ := gitlabclient.GetMROrgAndRepo(e)
 
-	mergeRequest, err := bot.cli.GetMergeRequest(e.Project.ID, e.ObjectAttributes.IID)
+	mergeRequest, err := getMergeRequest(bot.cli, e.Project.ID, e.ObjectAttributes.IID)
 	if err != nil {
 		return err
 	}
 
 	h := mergeHelper{
-		cfg:    cfg,
-		org:    org,
-		author: mergeRequest.Author.Username,
-		cli:    bot.cli,
-		mr:     &mergeRequest,
+		cfg:       cfg,
+		pid:       e.Project.ID,
+		mrID:      e.ObjectAttributes.IID,
+		org:       org,
+		author:    mergeRequest.Author.Username,
+		cli:       bot.cli,
+		directory: bot.directory,
+		rebases:   bot.rebases,
+		mr:        &mergeRequest,
 	}
 
 	if _, ok := h.canMerge(log); ok {
-		return h.merge()
+		return bot.doMerge(&h, log)
 	}
 
 	return nil
@@ -113,36 +125,150 @@ type mergeHelper struct {
 	author  string
 	trigger string
 
-	cli iClient
+	// sha is the head commit of PR which has been evaluated by canMerge.
+	// PR is merged only if its head is still this commit.
+	sha string
+
+	cli       iClient
+	directory *directoryCache
+	rebases   *botRebases
 }
 
-func (m *mergeHelper) merge() error {
+func (m *mergeHelper) merge(log *logrus.Entry) error {
+	method := m.mergeMethod()
+
+	p, err := m.cli.GetProject(m.pid)
+	if err != nil {
+		return err
+	}
+
+	if method == mergeMethodFFOnly && p.MergeMethod != gitlab.FastForwardMerge {
+		return fmt.Errorf(
+			"PR:%d can't be merged by %s, because the project does not only accept fast-forward merges",
+			m.mrID, method,
+		)
+	}
+
+	if m.needRebase(method, p) {
+		if err := m.rebase(); err != nil {
+			return err
+		}
+
+		if r, ok := m.canMerge(log); !ok {
+			log.Infof("PR:%d is not mergeable after rebasing, reasons: %s", m.mrID, strings.Join(r, " "))
+
+			return nil
+		}
+	}
 
-	desc := m.genMergeDesc()
-	fmt.Println("desc ", desc)
+	// the statuses may have to succeed before merging, such as when an owner merges a frozen branch.
+	if err := m.publishCommitStatus(log); err != nil {
+		log.WithError(err).Error("publish commit status")
+	}
 
-	opts := gitlab.UpdateMergeRequestOptions{Description: &desc, AssigneeIDs: &[]int{}, ReviewerIDs: &[]int{}}
-	_, err := m.cli.UpdateMergeRequest(m.pid, m.mrID, opts)
+	msg, err := m.genMergeCommitMessage(log)
 	if err != nil {
 		return err
 	}
 
-	return m.cli.MergeMergeRequest(
-		m.pid, m.mrID,
-	)
+	squash := method == mergeMethodSquash
+	removeSourceBranch := m.cfg.RemoveSourceBranch || m.mr.ForceRemoveSourceBranch
+
+	_, err = m.cli.AcceptMergeRequest(m.pid, m.mrID, gitlab.AcceptMergeRequestOptions{
+		MergeCommitMessage:       &msg,
+		SquashCommitMessage:      &msg,
+		Squash:                   &squash,
+		ShouldRemoveSourceBranch: &removeSourceBranch,
+		SHA:                      &m.sha,
+	})
+	if err != nil && isSHAMismatch(err) {
+		// the labels of PR may not have been cleared for the new commits yet, so it is
+		// re-evaluated when the event of the push is handled instead of right now.
+		log.Infof("the head of PR:%d has changed since %s was evaluated, skip merging it", m.mrID, m.sha)
+
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// the assignees and reviewers are cleared only when PR is merged, because the review SLA
+	// is tracked by the reviewers of PR which is still open.
+	opts := gitlab.UpdateMergeRequestOptions{AssigneeIDs: &[]int{}, ReviewerIDs: &[]int{}}
+	if _, err := m.cli.UpdateMergeRequest(m.pid, m.mrID, opts); err != nil {
+		log.WithError(err).Errorf("clear the assignees and reviewers of PR:%d", m.mrID)
+	}
+
+	return nil
+}
+
+// isSHAMismatch reports whether gitlab refused to merge PR because its head
+// is not the commit passed as the sha guard.
+func isSHAMismatch(err error) bool {
+	var v *gitlab.ErrorResponse
+	if errors.As(err, &v) && v.Response != nil && v.Response.StatusCode == http.StatusConflict {
+		return true
+	}
+
+	return strings.Contains(err.Error(), "SHA does not match HEAD")
+}
+
+// mergeMethod returns the method to merge PR, which can be overridden by a label such as merge/squash.
+func (m *mergeHelper) mergeMethod() pullRequestMergeMethod {
+	for l := range m.getMRLabels() {
+		if !strings.HasPrefix(l, mergeMethodLabelPrefix) {
+			continue
+		}
+
+		if v := pullRequestMergeMethod(strings.TrimPrefix(l, mergeMethodLabelPrefix)); v.isValid() {
+			return v
+		}
+	}
+
+	return m.cfg.MergeMethod
 }
 
 func (m *mergeHelper) canMerge(log *logrus.Entry) ([]string, bool) {
+	m.sha = m.mr.SHA
+
 	if m.mr.MergeStatus != canMergeStatus {
-		return []string{msgPRConflicts}, false
+		if !isBehindTarget(m.mr) {
+			return []string{m.cfg.message(msgPRConflicts, nil)}, false
+		}
+
+		if !m.cfg.AutoRebase && !m.mergeMethod().needRebase() {
+			return []string{m.cfg.message(msgPRNeedRebase, nil)}, false
+		}
 	}
 
+	return m.checkPolicy(log)
+}
+
+// checkPolicy checks whether the PR satisfies the review policy, regardless of
+// whether gitlab is able to merge it at present.
+func (m *mergeHelper) checkPolicy(log *logrus.Entry) ([]string, bool) {
 	ops, err := m.cli.GetMergeRequestLabelChanges(m.pid, m.mrID)
 	if err != nil {
 		return []string{}, false
 	}
 
-	if r := isLabelMatched(m.getMRLabels(), m.cfg, ops, log); len(r) > 0 {
+	bot, err := m.directory.botLogin(m.cli)
+	if err != nil {
+		log.WithError(err).Error("get the account of robot")
+
+		return []string{}, false
+	}
+
+	if r := isLabelMatched(m.getMRLabels(), m.cfg, ops, bot, log); len(r) > 0 {
+		return r, false
+	}
+
+	if r := m.checkLGTMQuorum(log); len(r) > 0 {
+		return r, false
+	}
+
+	if r := m.checkCodeOwners(log); len(r) > 0 {
 		return r, false
 	}
 
@@ -164,7 +290,7 @@ func (m *mergeHelper) canMerge(log *logrus.Entry) ([]string, bool) {
 	}
 
 	return []string{
-		fmt.Sprintf(msgFrozenWithOwner, strings.Join(freeze.Owner, ", ")),
+		m.cfg.message(msgFrozenWithOwner, messageArgs{"Owners": strings.Join(freeze.Owner, ", ")}),
 	}, false
 }
 
@@ -252,50 +378,8 @@ func (m *mergeHelper) getMRLabels() sets.String {
 	return labels
 }
 
-func (m *mergeHelper) genMergeDesc() string {
-	comments, err := m.cli.ListMergeRequestComments(m.pid, m.mrID)
-	fmt.Println(comments)
-	if err != nil || len(comments) == 0 {
-		return ""
-	}
-
-	f := func(comment gitlab.Note, reg *regexp.Regexp) bool {
-		fmt.Println("c ", reg.MatchString(comment.Body))
-		fmt.Println("c ", comment.UpdatedAt.String() == comment.CreatedAt.String())
-		fmt.Println("c ", comment.Author.Username != m.author)
-		return reg.MatchString(comment.Body) &&
-			comment.UpdatedAt.String() == comment.CreatedAt.String() &&
-			comment.Author.Username != m.author
-	}
-
-	reviewers := sets.NewString()
-	signers := sets.NewString()
-
-	for _, c := range comments {
-		fmt.Println("single comment ", c)
-		if f(*c, regAddLgtm) {
-			reviewers.Insert(c.Author.Username)
-		}
-
-		if f(*c, regAddApprove) {
-			signers.Insert(c.Author.Username)
-		}
-	}
-	fmt.Println("signers and reviewers ", signers, reviewers)
-
-	if len(signers) == 0 && len(reviewers) == 0 {
-		return ""
-	}
-
-	return fmt.Sprintf(
-		"From: @%s \nReviewed-by: @%s \nSigned-off-by: @%s \n",
-		m.author,
-		strings.Join(reviewers.UnsortedList(), ", @"),
-		strings.Join(signers.UnsortedList(), ", @"),
-	)
-}
-
-func isLabelMatched(labels sets.String, cfg *botConfig, ops []*gitlab.LabelEvent, log *logrus.Entry) []string {
+// isLabelMatched checks the labels of PR, which must be added by the robot whose username is bot.
+func isLabelMatched(labels sets.String, cfg *botConfig, ops []*gitlab.LabelEvent, bot string, log *logrus.Entry) []string {
 	var reasons []string
 
 	needs := sets.NewString(approvedLabel)
@@ -304,28 +388,29 @@ func isLabelMatched(labels sets.String, cfg *botConfig, ops []*gitlab.LabelEvent
 	if ln := cfg.LgtmCountsRequired; ln == 1 {
 		needs.Insert(lgtmLabel)
 	} else {
-		v := getLGTMLabelsOnPR(labels)
-		if n := uint(len(v)); n < ln {
-			reasons = append(reasons, fmt.Sprintf(msgNotEnoughLGTMLabel, ln, n)+"\n")
+		if n := lgtmCountOnPR(labels, cfg); n < ln {
+			reasons = append(reasons, cfg.message(
+				msgNotEnoughLGTMLabel, messageArgs{"Required": ln, "Got": n},
+			)+"\n")
 		}
 	}
 
-	s := checkLabelsLegal(labels, needs, ops, log)
+	s := checkLabelsLegal(labels, needs, ops, cfg, bot, log)
 	if s != "" {
 		reasons = append(reasons, s+"\n")
 	}
 
 	if v := needs.Difference(labels); v.Len() > 0 {
-		reasons = append(reasons, fmt.Sprintf(
-			msgMissingLabels, strings.Join(v.UnsortedList(), ", "),
+		reasons = append(reasons, cfg.message(
+			msgMissingLabels, messageArgs{"Labels": strings.Join(v.UnsortedList(), ", ")},
 		))
 	}
 
 	if len(cfg.MissingLabelsForMerge) > 0 {
 		missing := sets.NewString(cfg.MissingLabelsForMerge...)
 		if v := missing.Intersection(labels); v.Len() > 0 {
-			reasons = append(reasons, fmt.Sprintf(
-				msgInvalidLabels, strings.Join(v.UnsortedList(), ", "),
+			reasons = append(reasons, cfg.message(
+				msgInvalidLabels, messageArgs{"Labels": strings.Join(v.UnsortedList(), ", ")},
 			))
 		}
 	}
@@ -379,21 +464,23 @@ func getLatestLog(ops []*gitlab.LabelEvent, label string, log *logrus.Entry) (la
 	return labelLog{}, false
 }
 
-func checkLabelsLegal(labels sets.String, needs sets.String, ops []*gitlab.LabelEvent, log *logrus.Entry) string {
+func checkLabelsLegal(
+	labels sets.String, needs sets.String, ops []*gitlab.LabelEvent, cfg *botConfig, bot string, log *logrus.Entry,
+) string {
 	f := func(label string) string {
 		v, b := getLatestLog(ops, label, log)
 		if !b {
-			return fmt.Sprintf("The corresponding operation log is missing. you should delete " +
-				"the label and add it again by correct way")
+			return cfg.message(msgLabelLogMissing, nil)
 		}
 
-		if v.who != legalLabelsAddedBy {
+		if !strings.EqualFold(v.who, bot) {
+			args := messageArgs{"Who": v.who, "Label": v.label}
+
 			if strings.HasPrefix(v.label, "openeuler-cla/") {
-				return fmt.Sprintf("%s You can't add %s by yourself, "+
-					"please remove it and use /check-cla to add it", v.who, v.label)
+				return cfg.message(msgCLALabelAddedByUser, args)
 			}
 
-			return fmt.Sprintf("%s You can't add %s by yourself, please contact the maintainers", v.who, v.label)
+			return cfg.message(msgLabelAddedByUser, args)
 		}
 
 		return ""
@@ -402,7 +489,7 @@ func checkLabelsLegal(labels sets.String, needs sets.String, ops []*gitlab.Label
 	v := make([]string, 0, len(labels))
 
 	for label := range labels {
-		if ok := needs.Has(label); ok || strings.HasPrefix(label, lgtmLabel) {
+		if ok := needs.Has(label); ok || isLGTMCountLabel(label) {
 			if s := f(label); s != "" {
 				v = append(v, fmt.Sprintf("%s: %s", label, s))
 			}
@@ -410,13 +497,7 @@ func checkLabelsLegal(labels sets.String, needs sets.String, ops []*gitlab.Label
 	}
 
 	if n := len(v); n > 0 {
-		s := "label is"
-
-		if n > 1 {
-			s = "labels are"
-		}
-
-		return fmt.Sprintf("**The following %s not ready**.\n\n%s", s, strings.Join(v, "\n\n"))
+		return cfg.message(msgLabelsNotReady, messageArgs{"Count": n, "Details": strings.Join(v, "\n\n")})
 	}
 
 	return ""
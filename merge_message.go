@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultMergeCommitTemplate = `Merge branch '{{.SourceBranch}}' into '{{.TargetBranch}}'
+
+{{.Title}}
+
+See merge request {{.URL}}
+{{if .Trailers}}
+{{.Trailers}}{{end}}`
+
+// mergeCommitArgs is the data to execute the merge commit template.
+type mergeCommitArgs struct {
+	IID          int
+	Title        string
+	Description  string
+	SourceBranch string
+	TargetBranch string
+	URL          string
+
+	// Author, Reviewers and Approvers are the identities of the people, which are
+	// 'Name <email>' if they are found in the sig-info files, otherwise '@login'.
+	Author    string
+	Reviewers []string
+	Approvers []string
+
+	// Trailers are the lines of From, Reviewed-by and Signed-off-by.
+	Trailers string
+}
+
+func parseMergeCommitTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultMergeCommitTemplate
+	}
+
+	t, err := template.New("merge_commit").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	sample := mergeCommitArgs{
+		Author:    "@alice",
+		Reviewers: []string{"@bob"},
+		Approvers: []string{"@carol"},
+	}
+	if err := t.Execute(ioutil.Discard, &sample); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// genMergeCommitMessage renders the message of merge commit from the reviews of PR accepted by the robot.
+// It is also used as the squash commit message when the PR is squashed.
+func (m *mergeHelper) genMergeCommitMessage(log *logrus.Entry) (string, error) {
+	comments, err := m.cli.ListMergeRequestComments(m.pid, m.mrID)
+	if err != nil {
+		return "", err
+	}
+
+	reviewers, approvers, err := m.acceptedReviews(comments, log)
+	if err != nil {
+		return "", err
+	}
+
+	ids := m.loadMaintainerIdentities(log)
+
+	args := mergeCommitArgs{
+		IID:          m.mrID,
+		Title:        m.mr.Title,
+		Description:  m.mr.Description,
+		SourceBranch: m.mr.SourceBranch,
+		TargetBranch: m.mr.TargetBranch,
+		URL:          m.mr.WebURL,
+		Author:       formatIdentity(m.author, ids),
+	}
+
+	var trailers []string
+
+	if reviewers.Len() > 0 || approvers.Len() > 0 {
+		trailers = append(trailers, "From: "+args.Author)
+	}
+
+	for _, v := range reviewers.List() {
+		args.Reviewers = append(args.Reviewers, formatIdentity(v, ids))
+		trailers = append(trailers, "Reviewed-by: "+formatIdentity(v, ids))
+	}
+
+	for _, v := range approvers.List() {
+		args.Approvers = append(args.Approvers, formatIdentity(v, ids))
+		trailers = append(trailers, "Signed-off-by: "+formatIdentity(v, ids))
+	}
+
+	args.Trailers = strings.Join(trailers, "\n")
+
+	t := m.cfg.mergeCommitTmpl
+	if t == nil {
+		if t, err = parseMergeCommitTemplate(""); err != nil {
+			return "", err
+		}
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, &args); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// loadMaintainerIdentities loads the maintainers of all sig-info files in the sigs directory,
+// which is keyed by the lower case of login.
+func (m *mergeHelper) loadMaintainerIdentities(log *logrus.Entry) map[string]Maintainer {
+	if m.cfg.SigsDir == "" {
+		return nil
+	}
+
+	project, branch := m.cfg.sigsSource(m.pid, m.mr.TargetBranch)
+
+	_, sPath, err := listDirectoryTree(m.cli, project, branch, m.cfg)
+	if err != nil {
+		log.WithError(err).Error("list sig-info files")
+
+		return nil
+	}
+
+	ids := make(map[string]Maintainer)
+	logins := loadIdentities(m.cli, m.directory, m.cfg, log)
+
+	for _, s := range sPath {
+		if filepath.Base(s) != sigInfoFile {
+			continue
+		}
+
+		f, err := m.cli.GetPathContent(project, s, branch)
+		if err != nil || f == nil {
+			continue
+		}
+
+		info, err := parseSigInfoFile(f.Content)
+		if err != nil {
+			log.WithError(err).Errorf("parse %s", s)
+
+			continue
+		}
+
+		for _, v := range info.Maintainers {
+			if login := info.loginOf(sigPerson(v), logins); login != "" {
+				ids[strings.ToLower(login)] = v
+			}
+		}
+	}
+
+	return ids
+}
+
+func formatIdentity(login string, ids map[string]Maintainer) string {
+	v, ok := ids[strings.ToLower(login)]
+	if !ok || v.Email == "" {
+		return "@" + login
+	}
+
+	name := v.Name
+	if name == "" {
+		name = login
+	}
+
+	return fmt.Sprintf("%s <%s>", name, v.Email)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/opensourceways/community-robot-lib/gitlabclient"
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	maxMergeAttempts    = 5
+	mergeRetryBaseDelay = 30 * time.Second
+	mergeRetryMaxDelay  = 10 * time.Minute
+
+	// mergeFailedMarker marks the comment of merge failure with the head of PR.
+	mergeFailedMarker = "<!-- merge-failed: %s -->"
+)
+
+// isRetryableMergeError reports whether merging PR failed for a transient reason,
+// such as gitlab is still computing the mergeability of PR or it is unavailable.
+func isRetryableMergeError(err error) bool {
+	var v *gitlab.ErrorResponse
+	if errors.As(err, &v) && v.Response != nil {
+		switch code := v.Response.StatusCode; {
+		case code == http.StatusMethodNotAllowed, code == http.StatusNotAcceptable:
+			return true
+		case code == http.StatusTooManyRequests, code >= http.StatusInternalServerError:
+			return true
+		default:
+			return false
+		}
+	}
+
+	var ne net.Error
+
+	return errors.As(err, &ne)
+}
+
+// mergeFailureReason returns the reason responded by gitlab if possible.
+func mergeFailureReason(err error) string {
+	var v *gitlab.ErrorResponse
+	if errors.As(err, &v) && v.Message != "" {
+		return v.Message
+	}
+
+	return err.Error()
+}
+
+func mergeRetryDelay(attempt int) time.Duration {
+	d := mergeRetryBaseDelay
+	for i := 1; i < attempt && d < mergeRetryMaxDelay; i++ {
+		d *= 2
+	}
+
+	if d > mergeRetryMaxDelay {
+		d = mergeRetryMaxDelay
+	}
+
+	return d
+}
+
+func mergeTaskKey(pid, mrID int) string {
+	return fmt.Sprintf("merge/%d/%d", pid, mrID)
+}
+
+// doMerge merges PR which has been evaluated as mergeable. When it fails for a transient
+// reason, PR will be re-evaluated and merged later, otherwise the reason is commented on PR.
+func (bot *robot) doMerge(h *mergeHelper, log *logrus.Entry) error {
+	return bot.handleMergeError(h, h.merge(log), 1, log)
+}
+
+func (bot *robot) handleMergeError(h *mergeHelper, err error, attempt int, log *logrus.Entry) error {
+	if err == nil {
+		return nil
+	}
+
+	if !isRetryableMergeError(err) || attempt >= maxMergeAttempts {
+		if cerr := bot.commentMergeFailure(h, err, attempt); cerr != nil {
+			log.WithError(cerr).Errorf("comment the merge failure of PR:%d", h.mrID)
+		}
+
+		return err
+	}
+
+	delay := mergeRetryDelay(attempt)
+
+	log.WithError(err).Infof("failed to merge PR:%d at attempt %d, retry it after %s", h.mrID, attempt, delay)
+
+	retry := mergeHelper{
+		cfg:       h.cfg,
+		pid:       h.pid,
+		mrID:      h.mrID,
+		sha:       h.sha,
+		org:       h.org,
+		trigger:   h.trigger,
+		cli:       h.cli,
+		directory: h.directory,
+		rebases:   h.rebases,
+	}
+
+	bot.scheduler.schedule(mergeTaskKey(h.pid, h.mrID), delay, func() {
+		if err := bot.retryMerge(&retry, attempt+1, log); err != nil {
+			log.WithError(err).Errorf("retry merging PR:%d", retry.mrID)
+		}
+	})
+
+	return nil
+}
+
+// commentMergeFailure comments the reason why PR failed to be merged. PR is re-evaluated by
+// every event and the reconciler, so that it is commented only once for each head of PR.
+func (bot *robot) commentMergeFailure(h *mergeHelper, err error, attempt int) error {
+	notes, cerr := bot.cli.ListMergeRequestComments(h.pid, h.mrID)
+	if cerr != nil {
+		return cerr
+	}
+
+	login, cerr := bot.directory.botLogin(bot.cli)
+	if cerr != nil {
+		return cerr
+	}
+
+	marker := fmt.Sprintf(mergeFailedMarker, h.sha)
+
+	for _, n := range notes {
+		if !n.System && strings.EqualFold(n.Author.Username, login) && strings.Contains(n.Body, marker) {
+			return nil
+		}
+	}
+
+	return bot.cli.CreateMergeRequestComment(h.pid, h.mrID, h.cfg.message(
+		commentMergeFailed,
+		messageArgs{"Reason": mergeFailureReason(err), "Attempts": attempt},
+	)+"\n"+marker)
+}
+
+// retryMerge re-evaluates PR, because it may have changed since the last attempt.
+func (bot *robot) retryMerge(h *mergeHelper, attempt int, log *logrus.Entry) error {
+	mr, err := getMergeRequest(bot.cli, h.pid, h.mrID)
+	if err != nil {
+		return bot.handleMergeError(h, err, attempt, log)
+	}
+
+	if mr.State != gitlabclient.ActionOpened {
+		return nil
+	}
+
+	h.mr = &mr
+	h.author = mr.Author.Username
+
+	if r, ok := h.canMerge(log); !ok {
+		log.Infof("PR:%d is not mergeable when retrying to merge it, reasons: %v", h.mrID, r)
+
+		return nil
+	}
+
+	return bot.handleMergeError(h, h.merge(log), attempt, log)
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+)
+
+const (
+	localeEN = "en"
+	localeZH = "zh_CN"
+)
+
+// The names of comments posted by robot. Each of them is a Go text/template
+// which can be overridden by botConfig.CommentTemplates.
+const (
+	commentAddLGTMBySelf            = "add_lgtm_by_self"
+	commentClearLabel               = "clear_label"
+	commentNoPermissionForLgtmLabel = "no_permission_for_lgtm_label"
+	commentNoPermissionForLabel     = "no_permission_for_label"
+	commentAddLabel                 = "add_label"
+	commentRemovedLabel             = "removed_label"
+	commentRevokedByEdit            = "revoked_by_edit"
+	commentRebaseTriggered          = "rebase_triggered"
+	commentNoPermissionForRebase    = "no_permission_for_rebase"
+	commentNotMergeable             = "not_mergeable"
+	commentHelp                     = "help"
+	msgNotSetReviewer               = "not_set_reviewer"
+	msgPRConflicts                  = "pr_conflicts"
+	msgPRNeedRebase                 = "pr_need_rebase"
+	msgMissingLabels                = "missing_labels"
+	msgInvalidLabels                = "invalid_labels"
+	msgNotEnoughLGTMLabel           = "not_enough_lgtm_label"
+	msgFrozenWithOwner              = "frozen_with_owner"
+	msgLabelsNotReady               = "labels_not_ready"
+	msgLabelLogMissing              = "label_log_missing"
+	msgLabelAddedByUser             = "label_added_by_user"
+	msgCLALabelAddedByUser          = "cla_label_added_by_user"
+	msgMergeConditions              = "merge_conditions"
+	commentStatus                   = "status"
+	commentMergedWithoutReview      = "merged_without_review"
+	msgNotMergedByRobot             = "not_merged_by_robot"
+	msgBypassIssueTitle             = "bypass_issue_title"
+	msgBypassIssueBody              = "bypass_issue_body"
+	commentMergeFailed              = "merge_failed"
+	commentMarkedStale              = "marked_stale"
+	commentClosedStale              = "closed_stale"
+	commentReviewReminder           = "review_reminder"
+	commentReviewEscalation         = "review_escalation"
+	commentLGTMRecords              = "lgtm_records"
+	msgQuorumNotMet                 = "quorum_not_met"
+	msgCodeOwnersNotApproved        = "code_owners_not_approved"
+	commentUnmappedOwners           = "unmapped_owners"
+	msgExpiredOwnersIssueTitle      = "expired_owners_issue_title"
+	msgExpiredOwnersIssueBody       = "expired_owners_issue_body"
+)
+
+// messageArgs is the data to execute a comment template.
+type messageArgs map[string]interface{}
+
+// messageVars lists the variables which are available in each comment template
+// together with sample values used to validate the templates.
+var messageVars = map[string]messageArgs{
+	commentAddLGTMBySelf:            {},
+	commentClearLabel:               {"Labels": "lgtm, approved"},
+	commentNoPermissionForLgtmLabel: {"Commenter": "alice", "Eligible": "@bob"},
+	commentNoPermissionForLabel:     {"Commenter": "alice", "Action": "add", "Label": "approved", "Eligible": "@bob"},
+	commentAddLabel:                 {"Label": "lgtm", "Commenter": "alice"},
+	commentRemovedLabel:             {"Label": "lgtm", "Commenter": "alice"},
+	commentRevokedByEdit:            {"Labels": "lgtm, approved"},
+	commentRebaseTriggered:          {"Commenter": "alice"},
+	commentNoPermissionForRebase:    {"Commenter": "alice"},
+	commentNotMergeable:             {"Commenter": "alice", "Reasons": "reasons"},
+	commentHelp:                     {"Commenter": "alice", "Commands": "commands", "Conditions": "conditions"},
+	msgNotSetReviewer:               {"Author": "bob"},
+	msgPRConflicts:                  {},
+	msgPRNeedRebase:                 {},
+	msgMissingLabels:                {"Labels": "approved"},
+	msgInvalidLabels:                {"Labels": "ci-failed"},
+	msgNotEnoughLGTMLabel:           {"Required": uint(2), "Got": uint(1)},
+	msgFrozenWithOwner:              {"Owners": "alice, bob"},
+	msgLabelsNotReady:               {"Count": 2, "Details": "details"},
+	msgLabelLogMissing:              {},
+	msgLabelAddedByUser:             {"Who": "alice", "Label": "approved"},
+	msgCLALabelAddedByUser:          {"Who": "alice", "Label": "openeuler-cla/yes"},
+	msgMergeConditions: {
+		"LgtmCountsRequired":    uint(2),
+		"LgtmLabel":             "lgtm",
+		"LabelsForMerge":        "approved",
+		"MissingLabelsForMerge": "ci-failed",
+		"TargetBranch":          "master",
+		"FreezeKnown":           true,
+		"Frozen":                true,
+		"FreezeOwners":          "alice",
+	},
+	commentStatus: {
+		"Reviewers":     "@alice",
+		"Approvers":     "@bob",
+		"LgtmCount":     uint(1),
+		"LgtmRequired":  uint(1),
+		"MissingLabels": "approved",
+		"InvalidLabels": "ci-failed",
+		"TargetBranch":  "master",
+		"FreezeKnown":   true,
+		"Frozen":        true,
+		"FreezeOwners":  "alice",
+		"Mergeable":     false,
+		"Reasons":       "reasons",
+	},
+	commentMergedWithoutReview: {"MergedBy": "alice", "Reasons": "reasons"},
+	msgNotMergedByRobot:        {"MergedBy": "alice"},
+	msgBypassIssueTitle:        {"IID": 1, "Title": "title"},
+	msgBypassIssueBody:         {"IID": 1, "URL": "url", "MergedBy": "alice", "Reasons": "reasons"},
+	commentMergeFailed:         {"Reason": "reason", "Attempts": 1},
+	commentMarkedStale:         {"Days": 1, "CloseDays": 1},
+	commentClosedStale:         {"Days": 1},
+	commentReviewReminder:      {"Reviewers": "@bob", "Days": 1},
+	commentReviewEscalation:    {"Reviewers": "@bob", "Maintainers": "@carol", "Days": 1},
+	commentLGTMRecords:         {"Count": 1, "Required": uint(2), "Reviewers": "@alice"},
+	msgQuorumNotMet: {
+		"Required":      uint(1),
+		"Got":           uint(0),
+		"Roles":         "committer",
+		"Organizations": "org",
+		"Reviewers":     "@alice",
+	},
+	msgCodeOwnersNotApproved: {
+		"Section":  "Docs",
+		"Required": 1,
+		"Got":      0,
+		"Owners":   "@alice, @group/docs",
+	},
+	commentUnmappedOwners:      {"Commenter": "alice", "Owners": "bob, carol", "Fallbacks": "dave"},
+	msgExpiredOwnersIssueTitle: {},
+	msgExpiredOwnersIssueBody:  {"Count": 1, "Owners": "- **sig**: alice"},
+}
+
+var builtinMessages = map[string]map[string]string{
+	localeEN: {
+		commentAddLGTMBySelf: "***lgtm*** can not be added in your own merge request. :astonished:",
+		commentClearLabel:    "New code changes of the merge request are detected and these labels are removed: ***{{.Labels}}***. :flushed: ",
+		commentNoPermissionForLgtmLabel: `Thanks for your review, ***{{.Commenter}}***, your opinion is very important to us. :wave:
+The maintainers will consider your advice carefully.{{if .Eligible}}
+The people who can give lgtm are: {{.Eligible}}{{end}}`,
+		commentNoPermissionForLabel: `
+***@{{.Commenter}}*** has no permission to {{.Action}} ***{{.Label}}*** label in this merge request. :astonished:
+{{if .Eligible}}The people who can do it are: {{.Eligible}}{{else}}Please contact the collaborators in this repository.{{end}}`,
+		commentAddLabel: `***{{.Label}}*** was added to this merge request by: ***{{.Commenter}}***. :wave:
+**NOTE:** If this merge request is not merged while all conditions are met, comment "/check-pr" to try again. :smile: `,
+		commentRemovedLabel:    "***{{.Label}}*** was removed in this merge request by: ***{{.Commenter}}***. :flushed: ",
+		commentRevokedByEdit:   "***{{.Labels}}*** was removed in this merge request because the corresponding comments were edited or deleted. :flushed: ",
+		commentRebaseTriggered: "Rebasing this merge request onto the target branch was triggered by: ***{{.Commenter}}***. :wave: ",
+		commentNoPermissionForRebase: `
+***@{{.Commenter}}*** has no permission to rebase this merge request. :astonished:
+Only the author of the merge request and the collaborators in this repository can do it.`,
+		commentNotMergeable:    "@{{.Commenter}} , this merge request is not mergeable and the reasons are below:\n{{.Reasons}}",
+		commentHelp:            "@{{.Commenter}} , here are the available commands.\n\n{{.Commands}}\n\n{{.Conditions}}",
+		msgNotSetReviewer:      "**@{{.Author}}** Thank you for submitting a merge request. It is detected that you have not set a reviewer, please set one.",
+		msgPRConflicts:         "The merge request conflicts with the target branch.",
+		msgPRNeedRebase:        "The merge request is behind the target branch, comment /rebase to rebase it.",
+		msgMissingLabels:       "The merge request does not have these labels: {{.Labels}}",
+		msgInvalidLabels:       "The merge request should remove these labels: {{.Labels}}",
+		msgNotEnoughLGTMLabel:  "The merge request needs {{.Required}} lgtm labels and now gets {{.Got}}",
+		msgFrozenWithOwner:     "The target branch of the merge request has been frozen and it can be merged only by branch owners: {{.Owners}}",
+		msgLabelsNotReady:      "**The following {{if gt .Count 1}}labels are{{else}}label is{{end}} not ready**.\n\n{{.Details}}",
+		msgLabelLogMissing:     "The corresponding operation log is missing. You should delete the label and add it again in the correct way.",
+		msgLabelAddedByUser:    "{{.Who}} You can't add {{.Label}} by yourself, please contact the maintainers.",
+		msgCLALabelAddedByUser: "{{.Who}} You can't add {{.Label}} by yourself, please remove it and use /check-cla to add it.",
+		msgMergeConditions: `The merge request will be merged when all of the following conditions are met:
+{{if gt .LgtmCountsRequired 1}}- it gets {{.LgtmCountsRequired}} lgtm from different reviewers, shown by the ` + "`{{.LgtmLabel}}/<count>`" + ` label
+{{else}}- it has the ` + "`{{.LgtmLabel}}`" + ` label
+{{end}}- it has these labels: {{.LabelsForMerge}}
+{{if .MissingLabelsForMerge}}- it does not have these labels: {{.MissingLabelsForMerge}}
+{{end}}{{if not .FreezeKnown}}- the freeze status of the target branch is unknown at present{{else if .Frozen}}- the target branch ` + "`{{.TargetBranch}}`" + ` is frozen, so it can be merged only by: {{.FreezeOwners}}{{else}}- the target branch ` + "`{{.TargetBranch}}`" + ` is not frozen{{end}}`,
+		commentStatus: `### Review status
+
+| item | status |
+| ---- | ------ |
+| lgtm | {{.LgtmCount}}/{{.LgtmRequired}}{{if .Reviewers}}, given by {{.Reviewers}}{{end}} |
+| approved | {{if .Approvers}}given by {{.Approvers}}{{else}}not yet{{end}} |
+| missing labels | {{if .MissingLabels}}{{.MissingLabels}}{{else}}none{{end}} |
+| labels to remove | {{if .InvalidLabels}}{{.InvalidLabels}}{{else}}none{{end}} |
+| target branch | ` + "`{{.TargetBranch}}`" + ` {{if not .FreezeKnown}}freeze status unknown{{else if .Frozen}}frozen, can be merged only by: {{.FreezeOwners}}{{else}}not frozen{{end}} |
+| mergeable | {{if .Mergeable}}yes :white_check_mark:{{else}}no{{end}} |
+{{if .Reasons}}
+{{.Reasons}}{{end}}`,
+		commentMergedWithoutReview: "@{{.MergedBy}} , this merge request was merged without satisfying the review policy. :astonished:\n{{.Reasons}}",
+		msgNotMergedByRobot:        "It was merged by @{{.MergedBy}} instead of the robot.",
+		msgBypassIssueTitle:        "Merge request !{{.IID}} was merged without review: {{.Title}}",
+		msgBypassIssueBody:         "Merge request {{.URL}} was merged by @{{.MergedBy}} without satisfying the review policy.\n\n{{.Reasons}}",
+		commentMergeFailed:         "The robot failed to merge this merge request after {{.Attempts}} attempt(s). :confused:\n{{.Reason}}",
+		commentMarkedStale: `This merge request has had no activity for {{.Days}} days, so it is marked as ***lifecycle/stale***.
+{{if .CloseDays}}It will be closed if there is no activity in the next {{.CloseDays}} days. {{end}}Comment ` + "`/remove-lifecycle stale`" + ` to remove the label.`,
+		commentClosedStale:    "This stale merge request has had no activity for {{.Days}} days, so it is closed. Reopen it if it is still needed.",
+		commentReviewReminder: "{{.Reviewers}} , this merge request has been waiting for your review for more than {{.Days}} days. :bell:",
+		commentReviewEscalation: "{{if .Maintainers}}{{.Maintainers}} , {{end}}this merge request has been waiting for the review of {{.Reviewers}} for more than {{.Days}} days. " +
+			"{{if .Maintainers}}Please help to push it forward or reassign the reviewers.{{else}}No maintainer of the related sigs is found to escalate it.{{end}} :rotating_light:",
+		commentLGTMRecords: "**lgtm**: {{.Count}}/{{.Required}}{{if .Reviewers}}, given by {{.Reviewers}}{{end}}",
+		msgQuorumNotMet: "The merge request needs {{.Required}} lgtm from the " +
+			"{{if .Roles}}{{.Roles}}{{else}}members{{end}} of the related sigs" +
+			"{{if .Organizations}} in the organization {{.Organizations}}{{end}}" +
+			" and now gets {{.Got}}{{if .Reviewers}}: {{.Reviewers}}{{end}}",
+		msgCodeOwnersNotApproved: "The section {{.Section}} of CODEOWNERS needs {{.Required}} approval " +
+			"from its owners {{.Owners}} and now gets {{.Got}}",
+		commentUnmappedOwners: "@{{.Commenter}} , {{if .Owners}}the GitLab users of these owners are not found: ***{{.Owners}}***. " +
+			"Please add them to the identity mapping.{{else}}all of the owners are mapped to the GitLab users.{{end}}" +
+			"{{if .Fallbacks}} These owners are not mapped and their IDs are used as the GitLab usernames: " +
+			"***{{.Fallbacks}}***. Please make sure they are the same people.{{end}}",
+		msgExpiredOwnersIssueTitle: "Expired owners in the OWNERS and sig-info files",
+		msgExpiredOwnersIssueBody: "The owners of {{.Count}} sigs have expired and have no right any more. " +
+			"Please remove them or move them to `emeritus_approvers`.\n\n{{.Owners}}",
+	},
+
+	localeZH: {
+		commentAddLGTMBySelf: "不能在自己提交的合并请求上添加 ***lgtm*** 标签。 :astonished:",
+		commentClearLabel:    "检测到合并请求有新的代码提交，已移除以下标签：***{{.Labels}}***。 :flushed: ",
+		commentNoPermissionForLgtmLabel: "感谢您的检视，***{{.Commenter}}***，您的意见对我们非常重要。 :wave:\n维护者会认真考虑您的建议。" +
+			"{{if .Eligible}}\n可以给出lgtm的人员有：{{.Eligible}}{{end}}",
+		commentNoPermissionForLabel: `
+***@{{.Commenter}}*** 没有权限在此合并请求上{{if eq .Action "add"}}添加{{else}}移除{{end}} ***{{.Label}}*** 标签。 :astonished:
+{{if .Eligible}}有权限的人员有：{{.Eligible}}{{else}}请联系此仓库的协作者。{{end}}`,
+		commentAddLabel: `***{{.Commenter}}*** 为此合并请求添加了 ***{{.Label}}*** 标签。 :wave:
+**注意：** 如果在满足所有条件后合并请求仍未合入，请评论 "/check-pr" 重试。 :smile: `,
+		commentRemovedLabel:    "***{{.Commenter}}*** 移除了此合并请求的 ***{{.Label}}*** 标签。 :flushed: ",
+		commentRevokedByEdit:   "由于对应的评论被编辑或删除，已移除此合并请求的 ***{{.Labels}}*** 标签。 :flushed: ",
+		commentRebaseTriggered: "***{{.Commenter}}*** 触发了将此合并请求变基到目标分支的操作。 :wave: ",
+		commentNoPermissionForRebase: `
+***@{{.Commenter}}*** 没有权限对此合并请求进行变基。 :astonished:
+只有合并请求的作者和此仓库的协作者可以执行该操作。`,
+		commentNotMergeable:    "@{{.Commenter}} ，此合并请求暂不能合入，原因如下：\n{{.Reasons}}",
+		commentHelp:            "@{{.Commenter}} ，以下是可用的命令。\n\n{{.Commands}}\n\n{{.Conditions}}",
+		msgNotSetReviewer:      "**@{{.Author}}** 感谢您提交合并请求。检测到您尚未设置检视者，请设置一位。",
+		msgPRConflicts:         "合并请求与目标分支存在冲突。",
+		msgPRNeedRebase:        "合并请求落后于目标分支，请评论 /rebase 进行变基。",
+		msgMissingLabels:       "合并请求缺少以下标签：{{.Labels}}",
+		msgInvalidLabels:       "合并请求需要移除以下标签：{{.Labels}}",
+		msgNotEnoughLGTMLabel:  "合并请求需要 {{.Required}} 个 lgtm 标签，当前有 {{.Got}} 个",
+		msgFrozenWithOwner:     "合并请求的目标分支已冻结，只能由分支负责人合入：{{.Owners}}",
+		msgLabelsNotReady:      "**以下标签尚未就绪**。\n\n{{.Details}}",
+		msgLabelLogMissing:     "缺少对应的操作记录，请删除该标签后以正确的方式重新添加。",
+		msgLabelAddedByUser:    "{{.Who}} 不能自行添加 {{.Label}} 标签，请联系维护者。",
+		msgCLALabelAddedByUser: "{{.Who}} 不能自行添加 {{.Label}} 标签，请移除该标签并使用 /check-cla 添加。",
+		msgMergeConditions: `满足以下所有条件时合并请求将被合入：
+{{if gt .LgtmCountsRequired 1}}- 获得 {{.LgtmCountsRequired}} 位不同检视者的lgtm，由 ` + "`{{.LgtmLabel}}/<count>`" + ` 标签显示
+{{else}}- 拥有 ` + "`{{.LgtmLabel}}`" + ` 标签
+{{end}}- 拥有以下标签：{{.LabelsForMerge}}
+{{if .MissingLabelsForMerge}}- 没有以下标签：{{.MissingLabelsForMerge}}
+{{end}}{{if not .FreezeKnown}}- 目标分支的冻结状态暂时未知{{else if .Frozen}}- 目标分支 ` + "`{{.TargetBranch}}`" + ` 已冻结，只能由以下人员合入：{{.FreezeOwners}}{{else}}- 目标分支 ` + "`{{.TargetBranch}}`" + ` 未冻结{{end}}`,
+		commentStatus: `### 检视状态
+
+| 项目 | 状态 |
+| ---- | ---- |
+| lgtm | {{.LgtmCount}}/{{.LgtmRequired}}{{if .Reviewers}}，来自 {{.Reviewers}}{{end}} |
+| approved | {{if .Approvers}}来自 {{.Approvers}}{{else}}暂无{{end}} |
+| 缺少的标签 | {{if .MissingLabels}}{{.MissingLabels}}{{else}}无{{end}} |
+| 需移除的标签 | {{if .InvalidLabels}}{{.InvalidLabels}}{{else}}无{{end}} |
+| 目标分支 | ` + "`{{.TargetBranch}}`" + ` {{if not .FreezeKnown}}冻结状态未知{{else if .Frozen}}已冻结，只能由以下人员合入：{{.FreezeOwners}}{{else}}未冻结{{end}} |
+| 可合入 | {{if .Mergeable}}是 :white_check_mark:{{else}}否{{end}} |
+{{if .Reasons}}
+{{.Reasons}}{{end}}`,
+		commentMergedWithoutReview: "@{{.MergedBy}} ，此合并请求在未满足检视规则的情况下被合入。 :astonished:\n{{.Reasons}}",
+		msgNotMergedByRobot:        "它由 @{{.MergedBy}} 而不是机器人合入。",
+		msgBypassIssueTitle:        "合并请求 !{{.IID}} 未经检视被合入：{{.Title}}",
+		msgBypassIssueBody:         "合并请求 {{.URL}} 由 @{{.MergedBy}} 在未满足检视规则的情况下合入。\n\n{{.Reasons}}",
+		commentMergeFailed:         "机器人尝试 {{.Attempts}} 次后仍未能合入此合并请求。 :confused:\n{{.Reason}}",
+		commentMarkedStale: `此合并请求已有 {{.Days}} 天没有任何活动，已被标记为 ***lifecycle/stale***。
+{{if .CloseDays}}若接下来 {{.CloseDays}} 天内仍没有活动，它将被关闭。{{end}}评论 ` + "`/remove-lifecycle stale`" + ` 可移除该标签。`,
+		commentClosedStale:    "此过期的合并请求已有 {{.Days}} 天没有任何活动，已被关闭。如仍需要，请重新打开。",
+		commentReviewReminder: "{{.Reviewers}} ，此合并请求等待您的检视已超过 {{.Days}} 天。 :bell:",
+		commentReviewEscalation: "{{if .Maintainers}}{{.Maintainers}} ，{{end}}此合并请求等待 {{.Reviewers}} 检视已超过 {{.Days}} 天，" +
+			"{{if .Maintainers}}请协助推动或重新指定检视者。{{else}}未找到相关sig的maintainer进行升级。{{end}} :rotating_light:",
+		commentLGTMRecords: "**lgtm**：{{.Count}}/{{.Required}}{{if .Reviewers}}，来自 {{.Reviewers}}{{end}}",
+		msgQuorumNotMet: "合并请求需要 {{.Required}} 个来自相关sig" +
+			"{{if .Organizations}}中组织为 {{.Organizations}} 的{{end}}" +
+			"{{if .Roles}} {{.Roles}} {{else}}成员{{end}}的 lgtm，当前有 {{.Got}} 个{{if .Reviewers}}：{{.Reviewers}}{{end}}",
+		msgCodeOwnersNotApproved: "CODEOWNERS 的 {{.Section}} 部分需要其所有者 {{.Owners}} 的 {{.Required}} 个 approve，" +
+			"当前有 {{.Got}} 个",
+		commentUnmappedOwners: "@{{.Commenter}} ，{{if .Owners}}未找到以下所有者对应的GitLab用户：***{{.Owners}}***，" +
+			"请将其添加到身份映射中。{{else}}所有的所有者都已映射到GitLab用户。{{end}}" +
+			"{{if .Fallbacks}}以下所有者未映射，其ID被直接用作GitLab用户名：***{{.Fallbacks}}***，请确认是同一个人。{{end}}",
+		msgExpiredOwnersIssueTitle: "OWNERS和sig-info文件中已过期的所有者",
+		msgExpiredOwnersIssueBody: "以下 {{.Count}} 个sig的所有者已过期，不再拥有任何权限。" +
+			"请将其移除或移动到`emeritus_approvers`中。\n\n{{.Owners}}",
+	},
+}
+
+var defaultMessages = mustMessageTemplates(localeEN)
+
+func mustMessageTemplates(locale string) map[string]*template.Template {
+	v, err := newMessageTemplates(locale, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
+
+// newMessageTemplates parses the built-in templates of locale and the overridden ones.
+func newMessageTemplates(locale string, overrides map[string]string) (map[string]*template.Template, error) {
+	if locale == "" {
+		locale = localeEN
+	}
+
+	bundle, ok := builtinMessages[locale]
+	if !ok {
+		return nil, fmt.Errorf("unsupported locale:%s", locale)
+	}
+
+	r := make(map[string]*template.Template, len(messageVars))
+
+	for k := range messageVars {
+		text, ok := overrides[k]
+		if !ok {
+			text = bundle[k]
+		}
+
+		t, err := parseMessageTemplate(k, text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid comment template %s, err:%s", k, err.Error())
+		}
+
+		r[k] = t
+	}
+
+	for k := range overrides {
+		if _, ok := messageVars[k]; !ok {
+			return nil, fmt.Errorf("unknown comment template:%s", k)
+		}
+	}
+
+	return r, nil
+}
+
+// parseMessageTemplate parses the template and executes it with sample data
+// to make sure it only refers to the available variables.
+func parseMessageTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, fmt.Errorf("empty template")
+	}
+
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.Execute(ioutil.Discard, messageVars[name]); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// message renders the comment template of name with args.
+func (c *botConfig) message(name string, args messageArgs) string {
+	var t *template.Template
+	if c != nil {
+		t = c.templates[name]
+	}
+
+	if t == nil {
+		t = defaultMessages[name]
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, args); err != nil {
+		b.Reset()
+		_ = defaultMessages[name].Execute(&b, args)
+	}
+
+	return b.String()
+}
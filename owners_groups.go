@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	ownersAliasesFile = "OWNERS_ALIASES"
+
+	// ownerGroupPrefix is the prefix of the entries of OWNERS file which reference
+	// a group of GitLab, such as '@group/subgroup'.
+	ownerGroupPrefix = "@"
+
+	directoryTTL = 10 * time.Minute
+)
+
+// ownersAliases is the content of OWNERS_ALIASES file at the root of repository.
+// An entry of OWNERS file which is the name of alias is expanded to its members.
+type ownersAliases struct {
+	Aliases map[string][]string `json:"aliases,omitempty"`
+}
+
+type directoryEntry struct {
+	users  sets.String
+	expiry time.Time
+}
+
+// directoryCache caches the users looked up from GitLab, such as the members of groups,
+// so that the API is not called on every comment.
+type directoryCache struct {
+	lock    sync.Mutex
+	entries map[string]directoryEntry
+	ttl     time.Duration
+}
+
+func newDirectoryCache(ttl time.Duration) *directoryCache {
+	return &directoryCache{entries: make(map[string]directoryEntry), ttl: ttl}
+}
+
+func (c *directoryCache) get(key string, load func() (sets.String, error)) (sets.String, error) {
+	key = strings.ToLower(key)
+
+	c.lock.Lock()
+	v, ok := c.entries[key]
+	c.lock.Unlock()
+
+	if ok && time.Now().Before(v.expiry) {
+		return v.users, nil
+	}
+
+	users, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	c.entries[key] = directoryEntry{users: users, expiry: time.Now().Add(c.ttl)}
+	c.lock.Unlock()
+
+	return users, nil
+}
+
+// membersOf returns the active members of group in lower case, including the inherited ones.
+// It returns empty if the group does not exist.
+func (c *directoryCache) membersOf(cli iClient, group string) (sets.String, error) {
+	return c.get("group:"+group, func() (sets.String, error) {
+		v, err := cli.GetGroupMembers(group)
+		if err != nil {
+			if isNotFound(err) {
+				return sets.NewString(), nil
+			}
+
+			return nil, err
+		}
+
+		r := sets.NewString()
+		for _, m := range v {
+			if m.State == "" || m.State == "active" {
+				r.Insert(strings.ToLower(m.Username))
+			}
+		}
+
+		return r, nil
+	})
+}
+
+// ownerResolver expands the entries of OWNERS files of a repository to the usernames.
+// The aliases are loaded once from the branch when they are needed.
+type ownerResolver struct {
+	cli       iClient
+	directory *directoryCache
+	ids       *identities
+	pid       int
+	branch    string
+	log       *logrus.Entry
+
+	aliases map[string][]string
+}
+
+func newOwnerResolver(
+	cli iClient, directory *directoryCache, cfg *botConfig, pid int, branch string, log *logrus.Entry,
+) *ownerResolver {
+	return &ownerResolver{
+		cli:       cli,
+		directory: directory,
+		ids:       loadIdentities(cli, directory, cfg, log),
+		pid:       pid,
+		branch:    branch,
+		log:       log,
+	}
+}
+
+func (bot *robot) newOwnerResolver(cfg *botConfig, pid int, branch string, log *logrus.Entry) *ownerResolver {
+	return newOwnerResolver(bot.cli, bot.directory, cfg, pid, branch, log)
+}
+
+func (r *ownerResolver) loadAliases() {
+	if r.aliases != nil {
+		return
+	}
+
+	r.aliases = map[string][]string{}
+
+	f, err := r.cli.GetPathContent(r.pid, ownersAliasesFile, r.branch)
+	if err != nil || f == nil {
+		return
+	}
+
+	c, err := base64.StdEncoding.DecodeString(f.Content)
+	if err != nil {
+		r.log.WithError(err).Errorf("decode %s file", ownersAliasesFile)
+
+		return
+	}
+
+	var m ownersAliases
+	if err := yaml.Unmarshal(c, &m); err != nil {
+		r.log.WithError(err).Errorf("parse %s file", ownersAliasesFile)
+
+		return
+	}
+
+	for k, v := range m.Aliases {
+		r.aliases[strings.ToLower(k)] = v
+	}
+}
+
+// expand returns the usernames in lower case referenced by the entries, which may be
+// usernames, aliases defined in OWNERS_ALIASES, or groups such as '@group/subgroup'.
+func (r *ownerResolver) expand(entries []string) sets.String {
+	people := sets.NewString()
+
+	for _, v := range r.expandAliases(entries) {
+		r.expandEntry(v, people)
+	}
+
+	return people
+}
+
+// expandAliases replaces the aliases in the entries with their members. An alias can not reference another one.
+func (r *ownerResolver) expandAliases(entries []string) []string {
+	var res []string
+
+	for _, v := range entries {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(v, ownerGroupPrefix) {
+			r.loadAliases()
+
+			if members, ok := r.aliases[strings.ToLower(v)]; ok {
+				for _, m := range members {
+					if m = strings.TrimSpace(m); m != "" {
+						res = append(res, m)
+					}
+				}
+
+				continue
+			}
+		}
+
+		res = append(res, v)
+	}
+
+	return res
+}
+
+// expandEntry adds the user or the members of group to people.
+func (r *ownerResolver) expandEntry(v string, people sets.String) {
+	if !strings.HasPrefix(v, ownerGroupPrefix) {
+		// the Gitee ID without a gitlab account is skipped if it is not used as the login.
+		if login := r.ids.gitlabLogin(v, ""); login != "" {
+			people.Insert(strings.ToLower(login))
+		}
+
+		return
+	}
+
+	group := strings.TrimPrefix(v, ownerGroupPrefix)
+
+	members, err := r.directory.membersOf(r.cli, group)
+	if err != nil {
+		r.log.WithError(err).Errorf("get the members of group %s", group)
+
+		return
+	}
+
+	people.Insert(members.UnsortedList()...)
+}
+
+func isNotFound(err error) bool {
+	var v *gitlab.ErrorResponse
+
+	return errors.As(err, &v) && v.Response != nil && v.Response.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	// untilLayout is the layout of the date until which an owner is valid. The owner
+	// is valid on that day and expires on the next day.
+	untilLayout = "2006-01-02"
+
+	defaultExpiredOwnersReportDays  = 7
+	defaultExpiredOwnersReportLabel = "expired-owners"
+)
+
+// ownerEntry is an entry of OWNERS file, which is either a login or an object with the
+// login and the date until which it is valid, such as '{login: alice, until: 2024-12-31}'.
+type ownerEntry struct {
+	Login string `json:"login"`
+	Until string `json:"until,omitempty"`
+}
+
+func (e *ownerEntry) UnmarshalJSON(b []byte) error {
+	var login string
+	if err := json.Unmarshal(b, &login); err == nil {
+		*e = ownerEntry{Login: login}
+
+		return nil
+	}
+
+	type entry ownerEntry
+
+	return json.Unmarshal(b, (*entry)(e))
+}
+
+func (e *ownerEntry) isExpired(now time.Time) bool {
+	return isExpired(e.Until, now)
+}
+
+// isExpired reports whether the date until which an entry is valid has passed. An entry
+// with an invalid date is regarded as expired, so that it does not grant any right by mistake.
+func isExpired(until string, now time.Time) bool {
+	if until == "" {
+		return false
+	}
+
+	t, err := time.Parse(untilLayout, until)
+	if err != nil {
+		return true
+	}
+
+	return !now.Before(t.AddDate(0, 0, 1))
+}
+
+// activeLogins returns the logins of the entries which have not expired and are not emeritus.
+func activeLogins(entries []ownerEntry, emeritus []ownerEntry) []string {
+	now := time.Now()
+
+	retired := make(map[string]bool, len(emeritus))
+	for i := range emeritus {
+		retired[strings.ToLower(emeritus[i].Login)] = true
+	}
+
+	var r []string
+	for i := range entries {
+		e := &entries[i]
+		if e.Login != "" && !e.isExpired(now) && !retired[strings.ToLower(e.Login)] {
+			r = append(r, e.Login)
+		}
+	}
+
+	return r
+}
+
+// expiredOwnersReportConfig specifies the report of the expired owners in the OWNERS and
+// sig-info files of the sigs directory. The report is kept in an issue of the repository.
+type expiredOwnersReportConfig struct {
+	// IntervalDays is the days between two checks. The default value is 7.
+	IntervalDays int `json:"interval_days,omitempty"`
+
+	// Label is the label of the report issue. The default value is expired-owners.
+	Label string `json:"label,omitempty"`
+}
+
+func (c *expiredOwnersReportConfig) setDefault() {
+	if c.IntervalDays == 0 {
+		c.IntervalDays = defaultExpiredOwnersReportDays
+	}
+
+	if c.Label == "" {
+		c.Label = defaultExpiredOwnersReportLabel
+	}
+}
+
+func (c *expiredOwnersReportConfig) validate() error {
+	if c.IntervalDays < 0 {
+		return fmt.Errorf("interval_days of expired_owners_report must not be negative")
+	}
+
+	return nil
+}
+
+// expiredOwners returns the expired entries of the OWNERS and sig-info files in the sigs
+// directory of the project and branch, which is keyed by the name of sig.
+func expiredOwners(
+	cli iClient, cfg *botConfig, project interface{}, branch string, log *logrus.Entry,
+) (map[string][]string, error) {
+	oPath, sPath, err := listDirectoryTree(cli, project, branch, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	r := make(map[string][]string)
+
+	add := func(file, login, until string) {
+		sig := path.Base(cfg.sigDirOf(file))
+		r[sig] = append(r[sig], fmt.Sprintf("%s (until %s in %s)", login, until, strings.TrimPrefix(file, cfg.sigsRoot()+"/")))
+	}
+
+	for _, p := range oPath {
+		f, err := cli.GetPathContent(project, p, branch)
+		if err != nil || f == nil {
+			continue
+		}
+
+		o, err := parseOwnerFile(f.Content)
+		if err != nil {
+			log.WithError(err).Errorf("parse %s", p)
+
+			continue
+		}
+
+		for _, entries := range [][]ownerEntry{o.Approvers, o.Reviewers, o.Maintainers, o.Committers} {
+			for i := range entries {
+				if e := &entries[i]; e.isExpired(now) {
+					add(p, e.Login, e.Until)
+				}
+			}
+		}
+	}
+
+	for _, p := range sPath {
+		f, err := cli.GetPathContent(project, p, branch)
+		if err != nil || f == nil {
+			continue
+		}
+
+		info, err := parseSigInfoFile(f.Content)
+		if err != nil {
+			log.WithError(err).Errorf("parse %s", p)
+
+			continue
+		}
+
+		for _, v := range info.persons() {
+			if !isExpired(v.Until, now) {
+				continue
+			}
+
+			login := v.GitlabID
+			if login == "" {
+				login = v.GiteeID
+			}
+
+			add(p, login, v.Until)
+		}
+	}
+
+	return r, nil
+}
+
+// reportExpiredOwners reports the expired owners by sig in the open issue labeled as the report,
+// and creates the issue if there is not one. The report is kept in the project holding the sigs
+// directory, which may be shared by the targets when sigs_repo is set. It checks at most once
+// every interval_days for each project, even if the check failed.
+func (r *reconciler) reportExpiredOwners(cfg *botConfig, t reconcileTarget, log *logrus.Entry) {
+	report := cfg.ExpiredOwnersReport
+	if report == nil || cfg.SigsDir == "" {
+		return
+	}
+
+	// the branch is the default one of the project of PR if sigs_repo is not set.
+	project, branch := cfg.sigsSource(t.pid, "")
+	key := fmt.Sprintf("%v:%s", project, branch)
+
+	if last, ok := r.reports[key]; ok && time.Since(last) < time.Duration(report.IntervalDays)*day {
+		return
+	}
+
+	r.reports[key] = time.Now()
+
+	if branch == "" {
+		p, err := r.bot.cli.GetProject(t.pid)
+		if err != nil {
+			log.WithError(err).Errorf("get the project %s/%s", t.org, t.repo)
+
+			return
+		}
+
+		branch = p.DefaultBranch
+	}
+
+	expired, err := expiredOwners(r.bot.cli, cfg, project, branch, log)
+	if err != nil {
+		log.WithError(err).Errorf("list the expired owners of %v", project)
+
+		return
+	}
+
+	if len(expired) == 0 {
+		return
+	}
+
+	sigs := make([]string, 0, len(expired))
+	for k := range expired {
+		sigs = append(sigs, k)
+	}
+
+	sort.Strings(sigs)
+
+	lines := make([]string, 0, len(sigs))
+	for _, sig := range sigs {
+		lines = append(lines, fmt.Sprintf("- **%s**: %s", sig, strings.Join(expired[sig], ", ")))
+	}
+
+	title := cfg.message(msgExpiredOwnersIssueTitle, nil)
+	desc := cfg.message(msgExpiredOwnersIssueBody, messageArgs{
+		"Count":  len(sigs),
+		"Owners": strings.Join(lines, "\n"),
+	})
+
+	issue, err := openIssueLabeled(r.bot.cli, project, report.Label)
+	if err != nil {
+		log.WithError(err).Errorf("find the report of expired owners of %v", project)
+
+		return
+	}
+
+	if issue != nil {
+		if issue.Title == title && issue.Description == desc {
+			return
+		}
+
+		if _, err := r.bot.cli.UpdateIssue(project, issue.IID, gitlab.UpdateIssueOptions{
+			Title:       &title,
+			Description: &desc,
+		}); err != nil {
+			log.WithError(err).Errorf("update the report of expired owners of %v", project)
+		}
+
+		return
+	}
+
+	labels := gitlab.Labels{report.Label}
+
+	if _, err := r.bot.cli.CreateIssue(project, gitlab.CreateIssueOptions{
+		Title:       &title,
+		Description: &desc,
+		Labels:      &labels,
+	}); err != nil {
+		log.WithError(err).Errorf("create the report of expired owners of %v", project)
+	}
+}
+
+// openIssueLabeled returns the latest open issue with the label. It returns nil if there is not one.
+func openIssueLabeled(cli iClient, project interface{}, label string) (*gitlab.Issue, error) {
+	state := "opened"
+	labels := gitlab.Labels{label}
+
+	issues, err := cli.ListProjectIssues(project, gitlab.ListProjectIssuesOptions{State: &state, Labels: &labels})
+	if err != nil || len(issues) == 0 {
+		return nil, err
+	}
+
+	return issues[0], nil
+}
@@ -2,9 +2,8 @@ package main
 
 import (
 	"encoding/base64"
-	"fmt"
 	"github.com/xanzy/go-gitlab"
-	"path/filepath"
+	"path"
 	"strings"
 
 	"github.com/opensourceways/repo-file-cache/models"
@@ -17,7 +16,7 @@ const ownerFile = "OWNERS"
 const sigInfoFile = "sig-info.yaml"
 
 func (bot *robot) hasPermission(
-	org, repo, commenter string,
+	org, repo, commenter, cmd string,
 	commenterID int,
 	needCheckSig bool,
 	e *gitlab.MergeCommentEvent,
@@ -25,7 +24,7 @@ func (bot *robot) hasPermission(
 	log *logrus.Entry,
 ) (bool, error) {
 	commenter = strings.ToLower(commenter)
-	hasPermission, err := bot.cli.GetUserPermissionOfProject(e.ProjectID, commenterID)
+	hasPermission, err := bot.isCollaborator(cfg, cmd, e.ProjectID, commenterID)
 	if err != nil {
 		return false, err
 	}
@@ -35,12 +34,41 @@ func (bot *robot) hasPermission(
 	}
 
 	if needCheckSig {
-		return bot.isOwnerOfSig(org, repo, commenter, e, cfg, log)
+		if v, err := bot.isOwnerOfSig(org, repo, commenter, e, cfg, log); err != nil || v {
+			return v, err
+		}
+
+		return bot.isOwnerOfRepo(org+"/"+repo, commenter, e, cfg, log), nil
 	}
 
 	return false, nil
 }
 
+// isOwnerOfRepo checks whether the commenter is a maintainer of the sigs owning the repository,
+// or an admin or committer of the repository in their sig-info files.
+func (bot *robot) isOwnerOfRepo(
+	repo, commenter string,
+	e *gitlab.MergeCommentEvent,
+	cfg *botConfig,
+	log *logrus.Entry,
+) bool {
+	var sigs []SigInfos
+	for _, s := range loadSigsOfPR(bot.cli, cfg, e.ProjectID, e.MergeRequest.IID, e.MergeRequest.TargetBranch, repo, log) {
+		if s.ownsRepo(repo) {
+			sigs = append(sigs, s)
+		}
+	}
+
+	ids := loadIdentities(bot.cli, bot.directory, cfg, log)
+	m, ok := sigMembersOf(sigs, repo, ids)[commenter]
+
+	return ok && m.roles.HasAny(sigRoleMaintainer, sigRoleAdmin, sigRoleCommitter)
+}
+
+// isOwnerOfSig checks whether the commenter owns all of the files changed by PR in the sigs
+// directory. A file is owned by the people in the OWNERS file of its directory or one of
+// its ancestors within the layout. At the directory of sig, the sig-info file is used if
+// there is no OWNERS file.
 func (bot *robot) isOwnerOfSig(
 	org, repo, commenter string,
 	e *gitlab.MergeCommentEvent,
@@ -52,135 +80,108 @@ func (bot *robot) isOwnerOfSig(
 		return false, err
 	}
 
-	paths := sets.NewString()
+	var files []string
 	for _, file := range changes {
 		if file == "" {
 			continue
 		}
-		if !cfg.regSigDir.MatchString(file) || strings.Count(file, "/") > 2 {
-			fmt.Println("return")
+
+		if cfg.sigDirOf(file) == "" {
 			return false, nil
 		}
 
-		paths.Insert(filepath.Dir(file))
+		files = append(files, file)
 	}
 
-	fmt.Println("paths == ", paths)
-
 	// get directory tree
-	oPath, sPath, err := bot.listDirectoryTree(e.ProjectID, "master", cfg.SigsDir)
-	if err != nil || len(oPath) == 0 || len(sPath) == 0 {
+	oPath, sPath, err := listDirectoryTree(bot.cli, e.ProjectID, e.MergeRequest.TargetBranch, cfg)
+	if err != nil || (len(oPath) == 0 && len(sPath) == 0) {
 		return false, nil
 	}
 
-	for _, o := range oPath {
-		p := filepath.Dir(o)
-		if !paths.Has(p) {
-			continue
-		}
+	ownersFiles := sets.NewString(oPath...)
+	sigInfoFiles := make(map[string]string, len(sPath))
+	for _, s := range sPath {
+		sigInfoFiles[cfg.sigDirOf(s)] = s
+	}
 
-		fmt.Println("geeeeeeettttttt in")
-		oFile, err := bot.cli.GetPathContent(e.ProjectID, o, "master")
-		if err != nil || oFile == nil {
-			return false, nil
+	resolver := bot.newOwnerResolver(cfg, e.ProjectID, e.MergeRequest.TargetBranch, log)
+
+	owners := map[string]sets.String{}
+	ownersOf := func(p string, isSigInfo bool) sets.String {
+		if v, ok := owners[p]; ok {
+			return v
 		}
 
-		if o := decodeOwnerFile(oFile.Content, log); !o.Has(commenter) {
-			return false, nil
+		v := sets.NewString()
+		if f, err := bot.cli.GetPathContent(e.ProjectID, p, e.MergeRequest.TargetBranch); err == nil && f != nil {
+			if isSigInfo {
+				v = decodeSigInfoFile(f.Content, org+"/"+repo, resolver.ids, log)
+			} else {
+				v = decodeOwnerFile(f.Content, resolver, log)
+			}
 		}
 
-		paths.Delete(p)
+		owners[p] = v
 
-		if len(paths) == 0 {
-			return true, nil
-		}
+		return v
 	}
 
-	for _, s := range sPath {
-		p := filepath.Dir(s)
-		if !paths.Has(p) {
-			continue
-		}
-
-		sFile, err := bot.cli.GetPathContent(e.ProjectID, s, "master")
-		if err != nil || sFile == nil {
+	for _, file := range files {
+		if !isOwnerOfFile(cfg, file, commenter, ownersFiles, sigInfoFiles, ownersOf) {
 			return false, nil
 		}
+	}
 
-		if o := decodeSigInfoFile(sFile.Content, log); !o.Has(commenter) {
-			return false, nil
+	return true, nil
+}
+
+func isOwnerOfFile(
+	cfg *botConfig, file, commenter string,
+	ownersFiles sets.String, sigInfoFiles map[string]string,
+	ownersOf func(string, bool) sets.String,
+) bool {
+	sigDir := cfg.sigDirOf(file)
+
+	for _, d := range cfg.ownerDirsOf(file) {
+		if p := path.Join(d, ownerFile); ownersFiles.Has(p) {
+			if ownersOf(p, false).Has(commenter) {
+				return true
+			}
+
+			continue
 		}
 
-		paths.Delete(p)
+		if d != sigDir {
+			continue
+		}
 
-		if len(paths) == 0 {
-			return true, nil
+		if s, ok := sigInfoFiles[d]; ok && ownersOf(s, true).Has(commenter) {
+			return true
 		}
 	}
 
-	//ownerFiles, err := bot.getFiles(org, repo, e.MergeRequest.TargetBranch, ownerFile, log)
-	//if err != nil {
-	//	return false, err
-	//}
-	//
-	//sigInfoFiles, err := bot.getFiles(org, repo, e.MergeRequest.TargetBranch, sigInfoFile, log)
-	//if err != nil {
-	//	return false, err
-	//}
-	//
-	//for _, v := range ownerFiles.Files {
-	//	p := v.Path.Dir()
-	//	if !paths.Has(p) {
-	//		continue
-	//	}
-	//
-	//	if o := decodeOwnerFile(v.Content, log); !o.Has(commenter) {
-	//		return false, nil
-	//	}
-	//
-	//	paths.Delete(p)
-	//
-	//	if len(paths) == 0 {
-	//		return true, nil
-	//	}
-	//}
-	//
-	//for _, v := range sigInfoFiles.Files {
-	//	p := v.Path.Dir()
-	//	if !paths.Has(p) {
-	//		continue
-	//	}
-	//
-	//	if o := decodeSigInfoFile(v.Content, log); !o.Has(commenter) {
-	//		return false, nil
-	//	}
-	//
-	//	paths.Delete(p)
-	//
-	//	if len(paths) == 0 {
-	//		return true, nil
-	//	}
-	//}
-
-	return false, nil
+	return false
 }
 
-func (bot *robot) listDirectoryTree(pid int, branch, dirPath string) ([]string, []string, error) {
+// listDirectoryTree returns the OWNERS and sig-info files in the sigs directory which match the layout.
+func listDirectoryTree(cli iClient, pid interface{}, branch string, cfg *botConfig) ([]string, []string, error) {
 	recursive := true
+	dirPath := cfg.sigsRoot()
 	ownerFilePath := make([]string, 0)
 	sigInfoFilePath := make([]string, 0)
 	opt := gitlab.ListTreeOptions{Path: &dirPath, Ref: &branch, Recursive: &recursive}
-	trees, err := bot.cli.GetDirectoryTree(pid, opt)
+	trees, err := cli.GetDirectoryTree(pid, opt)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	for _, t := range trees {
-		if strings.Count(t.Path, "/") == 2 && strings.Contains(t.Path, ownerFile) {
+		if cfg.isSigOwnersFile(t.Path) {
 			ownerFilePath = append(ownerFilePath, t.Path)
 		}
 
-		if strings.Count(t.Path, "/") == 2 && strings.Contains(t.Path, sigInfoFile) {
+		if cfg.isSigInfoFile(t.Path) {
 			sigInfoFilePath = append(sigInfoFilePath, t.Path)
 		}
 	}
@@ -215,62 +216,93 @@ func (bot *robot) getFiles(org, repo, branch, fileName string, log *logrus.Entry
 	return files, nil
 }
 
-func decodeSigInfoFile(content string, log *logrus.Entry) sets.String {
-	owners := sets.NewString()
+func parseSigInfoFile(content string) (SigInfos, error) {
+	var m SigInfos
 
 	c, err := base64.StdEncoding.DecodeString(content)
 	if err != nil {
-		log.WithError(err).Error("decode file")
-
-		return owners
+		return m, err
 	}
 
-	var m SigInfos
+	err = yaml.Unmarshal(c, &m)
 
-	if err = yaml.Unmarshal(c, &m); err != nil {
-		log.WithError(err).Error("code yaml file")
+	return m, err
+}
 
-		return owners
-	}
+// ownersFile is the content of OWNERS file.
+type ownersFile struct {
+	Approvers   []ownerEntry `json:"approvers,omitempty"`
+	Reviewers   []ownerEntry `json:"reviewers,omitempty"`
+	Maintainers []ownerEntry `json:"maintainers,omitempty"`
+	Committers  []ownerEntry `json:"committers,omitempty"`
 
-	for _, v := range m.Maintainers {
-		owners.Insert(strings.ToLower(v.GiteeID))
+	// EmeritusApprovers are the people who have retired. They have no right any more
+	// even if they are still listed in the other fields.
+	EmeritusApprovers []ownerEntry `json:"emeritus_approvers,omitempty"`
+}
+
+func parseOwnerFile(content string) (ownersFile, error) {
+	var m ownersFile
+
+	c, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return m, err
 	}
 
-	fmt.Println("owners ******************** ", owners)
+	err = yaml.Unmarshal(c, &m)
 
-	return owners
+	return m, err
 }
 
-func decodeOwnerFile(content string, log *logrus.Entry) sets.String {
+// decodeSigInfoFile returns the maintainers of sig, together with the admins and
+// committers of repo, such as 'org/repo'.
+func decodeSigInfoFile(content, repo string, ids *identities, log *logrus.Entry) sets.String {
 	owners := sets.NewString()
 
-	c, err := base64.StdEncoding.DecodeString(content)
+	m, err := parseSigInfoFile(content)
 	if err != nil {
-		log.WithError(err).Error("decode file")
+		log.WithError(err).Error("decode sig-info file")
 
 		return owners
 	}
 
-	var m struct {
-		Maintainers []string `yaml:"maintainers"`
-		Committers  []string `yaml:"committers"`
+	insert := func(login string) {
+		if login != "" {
+			owners.Insert(strings.ToLower(login))
+		}
 	}
 
-	if err = yaml.Unmarshal(c, &m); err != nil {
-		log.WithError(err).Error("code yaml file")
-
-		return owners
+	for _, v := range m.Maintainers {
+		insert(m.loginOf(sigPerson(v), ids))
 	}
 
-	for _, v := range m.Maintainers {
-		owners.Insert(strings.ToLower(v))
+	for i := range m.Repositories {
+		r := &m.Repositories[i]
+		if !r.has(repo) {
+			continue
+		}
+
+		for _, v := range r.Admins {
+			insert(m.loginOf(sigPerson(v), ids))
+		}
+
+		for _, v := range r.Committers {
+			insert(m.loginOf(sigPerson(v), ids))
+		}
 	}
 
-	for _, v := range m.Committers {
-		owners.Insert(strings.ToLower(v))
+	return owners
+}
+
+func decodeOwnerFile(content string, resolver *ownerResolver, log *logrus.Entry) sets.String {
+	m, err := parseOwnerFile(content)
+	if err != nil {
+		log.WithError(err).Error("decode OWNERS file")
+
+		return sets.NewString()
 	}
 
-	fmt.Println("owners ************** ", owners)
+	owners := resolver.expand(m.peopleOf(sigRoleMaintainer)).Union(resolver.expand(m.peopleOf(sigRoleCommitter)))
+
 	return owners
 }
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"path"
+	"strings"
+
+	"github.com/opensourceways/community-robot-lib/gitlabclient"
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// The roles which can be granted the permission of a command besides the ones of sig-info.
+const (
+	roleCollaborator = "collaborator"
+	roleApprover     = "approver"
+	roleReviewer     = "reviewer"
+	roleCodeOwner    = "codeowner"
+)
+
+var permissionRoles = sets.NewString(roleCollaborator, roleApprover, roleReviewer, roleCodeOwner).Union(sigRoles)
+
+// peopleOf returns the people holding the role, except the expired and emeritus ones.
+func (o *ownersFile) peopleOf(role string) []string {
+	switch role {
+	case roleApprover:
+		return activeLogins(o.Approvers, o.EmeritusApprovers)
+	case roleReviewer:
+		return activeLogins(o.Reviewers, o.EmeritusApprovers)
+	case sigRoleMaintainer:
+		return activeLogins(o.Maintainers, o.EmeritusApprovers)
+	case sigRoleCommitter:
+		return activeLogins(o.Committers, o.EmeritusApprovers)
+	}
+
+	return nil
+}
+
+// checkPermission checks whether the commenter can run a command which is granted to the roles.
+// It falls back to hasPermission if no role is specified. Otherwise, it also returns the people
+// holding the roles, except the collaborators. The access level configured for the command is
+// an alternative to the roles.
+func (bot *robot) checkPermission(
+	cmd string,
+	roles []string,
+	needCheckSig bool,
+	e *gitlab.MergeCommentEvent,
+	cfg *botConfig,
+	log *logrus.Entry,
+) (bool, []string, error) {
+	org, repo := gitlabclient.GetMRCommentOrgAndRepo(e)
+	commenter := gitlabclient.GetMRCommentAuthor(e)
+	commenterID := gitlabclient.GetMRCommentAuthorID(e)
+
+	if len(roles) == 0 {
+		v, err := bot.hasPermission(org, repo, commenter, cmd, commenterID, needCheckSig, e, cfg, log)
+
+		return v, nil, err
+	}
+
+	want := sets.NewString(roles...)
+
+	if _, ok := cfg.accessLevelOf(cmd); ok || want.Has(roleCollaborator) {
+		v, err := bot.isCollaborator(cfg, cmd, e.ProjectID, commenterID)
+		if err != nil || v {
+			return v, nil, err
+		}
+	}
+
+	holders := bot.roleHolders(want, org+"/"+repo, e, cfg, log)
+
+	return holders.Has(strings.ToLower(commenter)), holders.List(), nil
+}
+
+// roleHolders returns the people holding the roles for every file changed by PR. The holders
+// of a file are the ones in the OWNERS files of its directory and their parents, and its code
+// owners in the CODEOWNERS file. The people holding the roles in the sig-info files of the
+// related sigs are included too.
+func (bot *robot) roleHolders(
+	roles sets.String, repo string, e *gitlab.MergeCommentEvent, cfg *botConfig, log *logrus.Entry,
+) sets.String {
+	pid := e.ProjectID
+	number := e.MergeRequest.IID
+	branch := e.MergeRequest.TargetBranch
+
+	resolver := bot.newOwnerResolver(cfg, pid, branch, log)
+	changes, owners := bot.ownersFilesOfChanges(pid, number, branch, log)
+
+	var codeOwners *codeOwners
+	if roles.Has(roleCodeOwner) {
+		c, err := loadCodeOwners(bot.cli, pid, branch)
+		if err != nil {
+			log.WithError(err).Error("load the CODEOWNERS file")
+		}
+
+		codeOwners = c
+	}
+
+	holdersOfDir := map[string]sets.String{}
+	dirHolders := func(d string) sets.String {
+		if v, ok := holdersOfDir[d]; ok {
+			return v
+		}
+
+		v := sets.NewString()
+		if o, ok := owners[d]; ok {
+			for role := range roles {
+				v.Insert(resolver.expand(o.peopleOf(role)).UnsortedList()...)
+			}
+		}
+
+		holdersOfDir[d] = v
+
+		return v
+	}
+
+	var r sets.String
+
+	for _, f := range changes {
+		v := sets.NewString()
+
+		for d := path.Dir(f); ; d = path.Dir(d) {
+			v.Insert(dirHolders(d).UnsortedList()...)
+
+			if d == path.Dir(d) {
+				break
+			}
+		}
+
+		if codeOwners != nil {
+			v.Insert(resolver.expandCodeOwners(codeOwners.ownersOfFile(f)).UnsortedList()...)
+		}
+
+		if r == nil {
+			r = v
+		} else {
+			r = r.Intersection(v)
+		}
+	}
+
+	if r == nil {
+		r = sets.NewString()
+	}
+
+	for login, m := range sigMembersOf(loadSigsOfPR(bot.cli, cfg, pid, number, branch, repo, log), repo, resolver.ids) {
+		if m.roles.HasAny(roles.UnsortedList()...) {
+			r.Insert(login)
+		}
+	}
+
+	return r
+}
+
+// ownersFilesOfPR returns the OWNERS files of the directories changed by PR and their parents.
+func (bot *robot) ownersFilesOfPR(pid, mrID int, branch string, log *logrus.Entry) []ownersFile {
+	_, owners := bot.ownersFilesOfChanges(pid, mrID, branch, log)
+
+	r := make([]ownersFile, 0, len(owners))
+	for _, d := range sets.StringKeySet(owners).List() {
+		r = append(r, owners[d])
+	}
+
+	return r
+}
+
+// ownersFilesOfChanges returns the files changed by PR, and the OWNERS files of their
+// directories and the parents, which are keyed by directory.
+func (bot *robot) ownersFilesOfChanges(
+	pid, mrID int, branch string, log *logrus.Entry,
+) ([]string, map[string]ownersFile) {
+	changes, err := bot.cli.GetMergeRequestChanges(pid, mrID)
+	if err != nil {
+		log.WithError(err).Error("get the changes of PR")
+	}
+
+	dirs := sets.NewString()
+	for _, f := range changes {
+		for d := path.Dir(f); !dirs.Has(d); d = path.Dir(d) {
+			dirs.Insert(d)
+		}
+	}
+
+	r := map[string]ownersFile{}
+
+	for _, d := range dirs.List() {
+		f, err := bot.cli.GetPathContent(pid, path.Join(d, ownerFile), branch)
+		if err != nil || f == nil {
+			continue
+		}
+
+		o, err := parseOwnerFile(f.Content)
+		if err != nil {
+			log.WithError(err).Errorf("parse the OWNERS file of %s", d)
+
+			continue
+		}
+
+		r[d] = o
+	}
+
+	return changes, r
+}
+
+func formatEligible(people []string) string {
+	if len(people) == 0 {
+		return ""
+	}
+
+	return "@" + strings.Join(people, ", @")
+}
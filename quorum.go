@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// quorumRule requires some of lgtm to be given by the specified people of the sigs
+// whose directories are changed by PR or which own the repository of PR.
+type quorumRule struct {
+	// Count is the number of lgtm required by the rule.
+	Count uint `json:"count"`
+
+	// Roles are the roles in the sig-info files. Valid options are maintainer, mentor, admin,
+	// committer and contributor. Anyone of the sigs satisfies it if it is empty.
+	Roles []string `json:"roles,omitempty"`
+
+	// Organizations are the organizations in the sig-info files.
+	// Anyone of the sigs satisfies it if it is empty.
+	Organizations []string `json:"organizations,omitempty"`
+}
+
+func (r *quorumRule) validate() error {
+	if r.Count == 0 {
+		return fmt.Errorf("count of lgtm_quorum must be positive")
+	}
+
+	for _, v := range r.Roles {
+		if !sigRoles.Has(v) {
+			return fmt.Errorf("unsupported role of lgtm_quorum:%s", v)
+		}
+	}
+
+	return nil
+}
+
+func (r *quorumRule) matches(m *sigMember) bool {
+	if len(r.Roles) > 0 && !m.roles.HasAny(r.Roles...) {
+		return false
+	}
+
+	return len(r.Organizations) == 0 || m.organizations.HasAny(r.Organizations...)
+}
+
+// checkLGTMQuorum checks whether the people who have given lgtm satisfy every quorum rule.
+// It returns the reasons for the unmet rules.
+func (m *mergeHelper) checkLGTMQuorum(log *logrus.Entry) []string {
+	if len(m.cfg.LgtmQuorum) == 0 {
+		return nil
+	}
+
+	givers, err := m.lgtmGivers(log)
+	if err != nil {
+		log.WithError(err).Error("get the people who have given lgtm")
+
+		return []string{err.Error()}
+	}
+
+	p, err := m.cli.GetProject(m.pid)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	repo := p.PathWithNamespace
+	ids := loadIdentities(m.cli, m.directory, m.cfg, log)
+	members := sigMembersOf(loadSigsOfPR(m.cli, m.cfg, m.pid, m.mrID, m.mr.TargetBranch, repo, log), repo, ids)
+
+	var reasons []string
+
+	for i := range m.cfg.LgtmQuorum {
+		rule := &m.cfg.LgtmQuorum[i]
+
+		got := sets.NewString()
+		for u := range givers {
+			if v, ok := members[u]; ok && rule.matches(v) {
+				got.Insert(u)
+			}
+		}
+
+		if uint(got.Len()) >= rule.Count {
+			continue
+		}
+
+		reviewers := ""
+		if got.Len() > 0 {
+			reviewers = "@" + strings.Join(got.List(), ", @")
+		}
+
+		reasons = append(reasons, m.cfg.message(msgQuorumNotMet, messageArgs{
+			"Required":      rule.Count,
+			"Got":           uint(got.Len()),
+			"Roles":         strings.Join(rule.Roles, ", "),
+			"Organizations": strings.Join(rule.Organizations, ", "),
+			"Reviewers":     reviewers,
+		}))
+	}
+
+	return reasons
+}
+
+// lgtmGivers returns the people whose lgtm has been accepted by the robot, which are in lower case.
+func (m *mergeHelper) lgtmGivers(log *logrus.Entry) (sets.String, error) {
+	notes, err := m.cli.ListMergeRequestComments(m.pid, m.mrID)
+	if err != nil {
+		return nil, err
+	}
+
+	r, _, err := m.acceptedReviews(notes, log)
+
+	return r, err
+}
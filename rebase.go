@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opensourceways/community-robot-lib/gitlabclient"
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+)
+
+const (
+	cmdRebase = "rebase"
+
+	msgRebaseFailed = "PR can not be rebased onto the target branch: %s"
+
+	rebaseCheckInterval = 2 * time.Second
+	rebaseWaitTimeout   = time.Minute
+)
+
+var rebaseCommand = commandSpec{
+	names:       []string{cmdRebase},
+	syntax:      "/rebase",
+	examples:    []string{"/rebase"},
+	description: "Rebase the pull request onto the target branch.",
+	whoCanUse: func(cfg *botConfig) string {
+		return "The pull request author and " + strings.ToLower(whoHasPermission(cfg, cmdRebase, false)) + "."
+	},
+	handle: (*robot).handleRebase,
+}
+
+func (bot *robot) handleRebase(cmd command, e *gitlab.MergeCommentEvent, cfg *botConfig, log *logrus.Entry) error {
+	if e.MergeRequest.State != gitlabclient.ActionOpened || !cmd.hasArgs() {
+		return nil
+	}
+
+	org, repo := gitlabclient.GetMRCommentOrgAndRepo(e)
+	commenter := gitlabclient.GetMRCommentAuthor(e)
+	commenterID := gitlabclient.GetMRCommentAuthorID(e)
+	number := e.MergeRequest.IID
+	pid := e.ProjectID
+
+	if e.MergeRequest.AuthorID != commenterID {
+		v, err := bot.hasPermission(org, repo, commenter, cmdRebase, commenterID, false, e, cfg, log)
+		if err != nil {
+			return err
+		}
+
+		if !v {
+			return bot.cli.CreateMergeRequestComment(
+				pid, number, cfg.message(commentNoPermissionForRebase, messageArgs{"Commenter": commenter}),
+			)
+		}
+	}
+
+	if err := bot.cli.RebaseMergeRequest(pid, number); err != nil {
+		return err
+	}
+
+	return bot.cli.CreateMergeRequestComment(
+		pid, number, cfg.message(commentRebaseTriggered, messageArgs{"Commenter": commenter}),
+	)
+}
+
+// getMergeRequest gets PR together with the fields which gitlab fills only on request,
+// so that it can be told whether PR is behind the target branch or being rebased.
+func getMergeRequest(cli iClient, pid, mrID int) (gitlab.MergeRequest, error) {
+	return cli.GetMergeRequestWithOptions(pid, mrID, gitlab.GetMergeRequestsOptions{
+		IncludeDivergedCommitsCount: gitlab.Bool(true),
+		IncludeRebaseInProgress:     gitlab.Bool(true),
+	})
+}
+
+// isBehindTarget reports whether the PR can not be merged only because
+// the target branch has moved on since the PR was created.
+func isBehindTarget(mr *gitlab.MergeRequest) bool {
+	return !mr.HasConflicts && mr.DivergedCommitsCount > 0
+}
+
+func (m *mergeHelper) needRebase(method pullRequestMergeMethod, p *gitlab.Project) bool {
+	if !isBehindTarget(m.mr) {
+		return false
+	}
+
+	if method.needRebase() {
+		return true
+	}
+
+	return m.cfg.AutoRebase && (p.MergeMethod == gitlab.FastForwardMerge || p.MergeMethod == gitlab.RebaseMerge)
+}
+
+// rebase rebases the PR and waits until gitlab finishes it.
+// The merge request of helper will be refreshed when it is done.
+// The push of rebase is remembered, so that it does not clear the review labels.
+func (m *mergeHelper) rebase() error {
+	m.rebases.add(m.pid, m.mrID, m.mr.SHA)
+
+	if err := m.cli.RebaseMergeRequest(m.pid, m.mrID); err != nil {
+		m.rebases.remove(m.pid, m.mrID)
+
+		return err
+	}
+
+	for deadline := time.Now().Add(rebaseWaitTimeout); time.Now().Before(deadline); {
+		time.Sleep(rebaseCheckInterval)
+
+		mr, err := getMergeRequest(m.cli, m.pid, m.mrID)
+		if err != nil {
+			return err
+		}
+
+		if mr.MergeError != "" {
+			m.rebases.remove(m.pid, m.mrID)
+
+			return fmt.Errorf(msgRebaseFailed, mr.MergeError)
+		}
+
+		if !mr.RebaseInProgress && mr.DivergedCommitsCount == 0 {
+			m.mr = &mr
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("timeout to wait for the rebase of PR:%d", m.mrID)
+}
+
+// botRebases remembers the heads of PRs which are being rebased by the robot before merging.
+// The push of such a rebase only replays the reviewed commits onto the target branch, so that
+// the review labels are kept.
+type botRebases struct {
+	lock  sync.Mutex
+	heads map[string]string
+}
+
+func newBotRebases() *botRebases {
+	return &botRebases{heads: make(map[string]string)}
+}
+
+func rebaseKey(pid, mrID int) string {
+	return fmt.Sprintf("%d/%d", pid, mrID)
+}
+
+func (r *botRebases) add(pid, mrID int, sha string) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	r.heads[rebaseKey(pid, mrID)] = sha
+	r.lock.Unlock()
+}
+
+func (r *botRebases) remove(pid, mrID int) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	delete(r.heads, rebaseKey(pid, mrID))
+	r.lock.Unlock()
+}
+
+// isRebasedByBot reports whether the push replacing the head oldrev of PR is the rebase
+// made by the robot. The record is consumed by the push.
+func (r *botRebases) isRebasedByBot(pid, mrID int, oldrev string) bool {
+	if r == nil || oldrev == "" {
+		return false
+	}
+
+	k := rebaseKey(pid, mrID)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if sha, ok := r.heads[k]; ok && sha == oldrev {
+		delete(r.heads, k)
+
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opensourceways/community-robot-lib/gitlabclient"
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// reconcileJitter is the max proportion of interval added randomly to each round,
+// so that the replicas of robot do not reconcile at the same time.
+const reconcileJitter = 0.2
+
+// reconciler re-evaluates the open PRs periodically, in case the events were missed
+// or the merge conditions changed without any event, such as a branch is unfrozen.
+type reconciler struct {
+	// bot is a copy of robot whose calls of gitlab api are limited.
+	bot      *robot
+	interval time.Duration
+	limiter  *time.Ticker
+
+	// reports is the time of the last check of expired owners by the project and branch holding
+	// the sigs directory, which limits how often they are listed. It is only accessed by the
+	// goroutine of run.
+	reports map[string]time.Time
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+type reconcileTarget struct {
+	pid  int
+	org  string
+	repo string
+}
+
+// newReconciler creates a reconciler which runs every interval and calls
+// the gitlab api for at most qps times per second.
+func newReconciler(bot *robot, interval time.Duration, qps float64) *reconciler {
+	r := &reconciler{
+		interval: interval,
+		limiter:  time.NewTicker(time.Duration(float64(time.Second) / qps)),
+		reports:  make(map[string]time.Time),
+		done:     make(chan struct{}),
+	}
+
+	limited := *bot
+	limited.cli = &limitedClient{iClient: bot.cli, allow: r.wait}
+	r.bot = &limited
+
+	return r
+}
+
+func (r *reconciler) start() {
+	r.wg.Add(1)
+
+	go r.run()
+}
+
+func (r *reconciler) stop() {
+	close(r.done)
+
+	r.wg.Wait()
+
+	r.limiter.Stop()
+}
+
+func (r *reconciler) run() {
+	defer r.wg.Done()
+
+	for {
+		delay := r.interval + time.Duration(rand.Float64()*reconcileJitter*float64(r.interval))
+
+		select {
+		case <-r.done:
+			return
+		case <-time.After(delay):
+		}
+
+		r.reconcile(logrus.WithField("component", "reconciler"))
+	}
+}
+
+// wait blocks until the next call of gitlab api is allowed. It returns false when reconciler is stopped.
+func (r *reconciler) wait() bool {
+	select {
+	case <-r.done:
+		return false
+	case <-r.limiter.C:
+		return true
+	}
+}
+
+func (r *reconciler) stopped() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *reconciler) reconcile(log *logrus.Entry) {
+	c, err := r.bot.getConfig()
+	if err != nil {
+		log.WithError(err).Error("get config")
+
+		return
+	}
+
+	state := gitlabclient.ActionOpened
+	opts := gitlab.ListProjectMergeRequestsOptions{State: &state}
+
+	for _, t := range r.listTargets(c, log) {
+		cfg := c.configFor(t.org, t.repo)
+		if cfg == nil {
+			continue
+		}
+
+		r.reportExpiredOwners(cfg, t, log)
+
+		mrs, err := r.bot.cli.ListProjectMergeRequests(t.pid, opts)
+		if err != nil {
+			log.WithError(err).Errorf("list open PRs of %s/%s", t.org, t.repo)
+
+			continue
+		}
+
+		for _, mr := range mrs {
+			if r.stopped() {
+				return
+			}
+
+			l := log.WithField("pr", mr.WebURL)
+
+			closed, err := r.bot.handleLifecycle(cfg, t.pid, mr, l)
+			if err != nil {
+				l.WithError(err).Error("handle lifecycle")
+			}
+
+			if closed {
+				continue
+			}
+
+			if err := r.bot.handleReviewSLA(cfg, t.pid, t.org+"/"+t.repo, mr, l); err != nil {
+				l.WithError(err).Error("handle review SLA")
+			}
+
+			if err := r.bot.reconcileMR(cfg, t.pid, mr.IID, t.org, l); err != nil {
+				l.WithError(err).Error("reconcile PR")
+			}
+		}
+	}
+}
+
+// listTargets lists the projects covered by the config items.
+// An item of repos is either 'org' which covers all projects of the group or 'org/repo'.
+func (r *reconciler) listTargets(c *configuration, log *logrus.Entry) []reconcileTarget {
+	var orgs []string
+	var repos []string
+
+	for i := range c.ConfigItems {
+		for _, v := range c.ConfigItems[i].Repos {
+			if strings.Contains(v, "/") {
+				repos = append(repos, v)
+			} else {
+				orgs = append(orgs, v)
+			}
+		}
+	}
+
+	var targets []reconcileTarget
+
+	seen := sets.NewInt()
+	add := func(t reconcileTarget) {
+		if !seen.Has(t.pid) {
+			seen.Insert(t.pid)
+			targets = append(targets, t)
+		}
+	}
+
+	if len(orgs) > 0 {
+		grps, err := r.bot.cli.GetGroups()
+		if err != nil {
+			log.WithError(err).Error("list groups")
+		}
+
+		for _, org := range sets.NewString(orgs...).List() {
+			for _, g := range grps {
+				if g.Name != org {
+					continue
+				}
+
+				prjs, err := r.bot.cli.GetProjects(g.ID)
+				if err != nil {
+					log.WithError(err).Errorf("list projects of %s", org)
+
+					continue
+				}
+
+				for _, p := range prjs {
+					add(reconcileTarget{pid: p.ID, org: org, repo: p.Name})
+				}
+			}
+		}
+	}
+
+	for _, v := range sets.NewString(repos...).List() {
+		if r.stopped() {
+			break
+		}
+
+		p, err := r.bot.cli.GetProject(v)
+		if err != nil {
+			log.WithError(err).Errorf("get project %s", v)
+
+			continue
+		}
+
+		i := strings.LastIndex(v, "/")
+		add(reconcileTarget{pid: p.ID, org: v[:i], repo: v[i+1:]})
+	}
+
+	return targets
+}
+
+// reconcileMR evaluates an open PR as if an event had just happened to it.
+func (bot *robot) reconcileMR(cfg *botConfig, pid, mrID int, org string, log *logrus.Entry) error {
+	mr, err := getMergeRequest(bot.cli, pid, mrID)
+	if err != nil || mr.State != gitlabclient.ActionOpened {
+		return err
+	}
+
+	migrated, err := bot.migrateLGTMLabels(cfg, pid, &mr, log)
+	if err != nil {
+		return err
+	}
+
+	if migrated {
+		if mr, err = getMergeRequest(bot.cli, pid, mrID); err != nil {
+			return err
+		}
+	}
+
+	// gitlab does not notify the deletion of comments, so the review labels are checked here.
+	_, labels, err := bot.reconcileReviewLabels(cfg, pid, mrID, mr.Author.ID, log)
+	if err != nil {
+		return err
+	}
+
+	mr.Labels = labels.List()
+
+	h := mergeHelper{
+		cfg:       cfg,
+		pid:       pid,
+		mrID:      mrID,
+		org:       org,
+		author:    mr.Author.Username,
+		cli:       bot.cli,
+		directory: bot.directory,
+		rebases:   bot.rebases,
+		mr:        &mr,
+	}
+
+	if _, ok := h.canMerge(log); ok {
+		return bot.doMerge(&h, log)
+	}
+
+	return bot.syncReviewState(cfg, pid, mrID, org, log)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	slaReminderMarker   = "<!-- review-sla:reminder -->"
+	slaEscalationMarker = "<!-- review-sla:escalation -->"
+
+	slaStageReminder   = "reminder"
+	slaStageEscalation = "escalation"
+)
+
+// slaBreaches counts the breaches of review SLA by stage. It is published by expvar.
+var slaBreaches = expvar.NewMap("review_sla_breaches")
+
+// reviewSLAConfig specifies the time within which the reviewers of PR should respond.
+type reviewSLAConfig struct {
+	// FirstReviewDays is the days after PR is created within which each reviewer should
+	// comment on it. The reviewers who have not responded are reminded after then.
+	FirstReviewDays int `json:"first_review_days"`
+
+	// EscalationDays is the days after the reminder within which the reviewers should respond.
+	// The maintainers of sig are notified after then. It is not escalated if it is 0.
+	EscalationDays int `json:"escalation_days,omitempty"`
+}
+
+func (c *reviewSLAConfig) validate() error {
+	if c.FirstReviewDays <= 0 {
+		return fmt.Errorf("first_review_days of review_sla must be positive")
+	}
+
+	if c.EscalationDays < 0 {
+		return fmt.Errorf("escalation_days of review_sla must not be negative")
+	}
+
+	return nil
+}
+
+// handleReviewSLA reminds the reviewers of PR who have not responded in time,
+// and escalates to the maintainers of sig if they still do not respond.
+func (bot *robot) handleReviewSLA(cfg *botConfig, pid int, repo string, mr *gitlab.MergeRequest, log *logrus.Entry) error {
+	sla := cfg.ReviewSLA
+	if sla == nil || len(mr.Reviewers) == 0 || mr.CreatedAt == nil {
+		return nil
+	}
+
+	if time.Since(*mr.CreatedAt) < time.Duration(sla.FirstReviewDays)*day {
+		return nil
+	}
+
+	notes, err := bot.cli.ListMergeRequestComments(pid, mr.IID)
+	if err != nil {
+		return err
+	}
+
+	login, err := bot.directory.botLogin(bot.cli)
+	if err != nil {
+		return err
+	}
+
+	responded := sets.NewString()
+	var reminder, escalation *gitlab.Note
+
+	for _, n := range notes {
+		if n.System {
+			continue
+		}
+
+		if !strings.EqualFold(n.Author.Username, login) {
+			responded.Insert(strings.ToLower(n.Author.Username))
+
+			continue
+		}
+
+		if strings.Contains(n.Body, slaReminderMarker) {
+			reminder = n
+		}
+
+		if strings.Contains(n.Body, slaEscalationMarker) {
+			escalation = n
+		}
+	}
+
+	var pending []string
+	for _, v := range mr.Reviewers {
+		if !responded.Has(strings.ToLower(v.Username)) {
+			pending = append(pending, v.Username)
+		}
+	}
+
+	if len(pending) == 0 || escalation != nil {
+		return nil
+	}
+
+	reviewers := "@" + strings.Join(pending, ", @")
+
+	if reminder == nil {
+		recordSLABreach(slaStageReminder, mr, pending, log)
+
+		return bot.cli.CreateMergeRequestComment(pid, mr.IID, cfg.message(
+			commentReviewReminder, messageArgs{"Reviewers": reviewers, "Days": sla.FirstReviewDays},
+		)+"\n"+slaReminderMarker)
+	}
+
+	if sla.EscalationDays == 0 || reminder.CreatedAt == nil ||
+		time.Since(*reminder.CreatedAt) < time.Duration(sla.EscalationDays)*day {
+		return nil
+	}
+
+	recordSLABreach(slaStageEscalation, mr, pending, log)
+
+	// the escalation is commented even if there is no maintainer, so that the breach is recorded once.
+	mentions := ""
+	if maintainers := bot.sigMaintainersOfPR(cfg, pid, repo, mr.IID, mr.TargetBranch, log); maintainers.Len() > 0 {
+		mentions = "@" + strings.Join(maintainers.List(), ", @")
+	} else {
+		log.Infof("no maintainer is found to escalate the review of PR:%d", mr.IID)
+	}
+
+	return bot.cli.CreateMergeRequestComment(pid, mr.IID, cfg.message(
+		commentReviewEscalation, messageArgs{
+			"Reviewers":   reviewers,
+			"Maintainers": mentions,
+			"Days":        sla.FirstReviewDays + sla.EscalationDays,
+		},
+	)+"\n"+slaEscalationMarker)
+}
+
+// recordSLABreach counts the breach in metrics and writes it to the audit log.
+func recordSLABreach(stage string, mr *gitlab.MergeRequest, reviewers []string, log *logrus.Entry) {
+	slaBreaches.Add(stage, 1)
+
+	log.WithFields(logrus.Fields{
+		"audit":     "review_sla",
+		"stage":     stage,
+		"pr":        mr.WebURL,
+		"reviewers": reviewers,
+	}).Warn("the review SLA is breached")
+}
+
+// sigMaintainersOfPR returns the maintainers of the sigs whose directories are changed
+// by PR or which own the repository of PR.
+func (bot *robot) sigMaintainersOfPR(
+	cfg *botConfig, pid int, repo string, mrID int, branch string, log *logrus.Entry,
+) sets.String {
+	r := sets.NewString()
+	ids := loadIdentities(bot.cli, bot.directory, cfg, log)
+
+	for _, info := range loadSigsOfPR(bot.cli, cfg, pid, mrID, branch, repo, log) {
+		for _, v := range info.Maintainers {
+			if login := info.loginOf(sigPerson(v), ids); login != "" {
+				r.Insert(login)
+			}
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opensourceways/community-robot-lib/gitlabclient"
+	"github.com/opensourceways/community-robot-lib/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// reviewState is the review opinions derived from the current comments of PR.
+// A comment which has been edited counts with its latest content, and a deleted
+// one does not count any more.
+type reviewState struct {
+	// lgtm is the users who have given /lgtm and not canceled it.
+	lgtm sets.String
+
+	// approve is the users who have given /approve and not canceled it.
+	approve sets.String
+
+	// approveNotes is the comments in which the users of approve gave /approve at the last time.
+	approveNotes map[string]*gitlab.Note
+
+	// beforePush is the comments written before the latest commits were pushed.
+	// Their commands were given to the commits which have been replaced.
+	beforePush sets.Int
+}
+
+// isPushNote reports whether the note is the one gitlab writes when commits are pushed to PR.
+func isPushNote(n *gitlab.Note) bool {
+	return n.System && strings.HasPrefix(n.Body, "added ") && strings.Contains(n.Body, " commit")
+}
+
+func newReviewState(notes []*gitlab.Note, authorID int) reviewState {
+	s := reviewState{
+		lgtm:         sets.NewString(),
+		approve:      sets.NewString(),
+		approveNotes: map[string]*gitlab.Note{},
+		beforePush:   sets.NewInt(),
+	}
+
+	v := make([]*gitlab.Note, 0, len(notes))
+	for _, n := range notes {
+		if n != nil && n.CreatedAt != nil {
+			v = append(v, n)
+		}
+	}
+
+	sort.SliceStable(v, func(i, j int) bool {
+		return v[i].CreatedAt.Before(*v[j].CreatedAt)
+	})
+
+	var written []int
+
+	for _, n := range v {
+		if isPushNote(n) {
+			s.beforePush.Insert(written...)
+			written = written[:0]
+
+			continue
+		}
+
+		if n.System {
+			continue
+		}
+
+		written = append(written, n.ID)
+
+		user := n.Author.Username
+		byAuthor := n.Author.ID == authorID
+
+		for _, cmd := range parseCommands(n.Body) {
+			switch cmd.name {
+			case cmdLGTM:
+				if cmd.hasArgs() && !byAuthor {
+					s.lgtm.Insert(user)
+				}
+
+				if cmd.hasArgs("cancel") {
+					if byAuthor {
+						// the author of pr can remove all of lgtm
+						s.lgtm = sets.NewString()
+					} else {
+						s.lgtm.Delete(user)
+					}
+				}
+
+			case cmdApprove, cmdApproved:
+				if cmd.hasArgs() {
+					s.approve.Insert(user)
+					s.approveNotes[user] = n
+				}
+
+				if cmd.hasArgs("cancel") {
+					s.approve.Delete(user)
+					delete(s.approveNotes, user)
+				}
+			}
+		}
+	}
+
+	return s
+}
+
+// staleLabels returns the review labels on PR which are not backed by the accepted commands,
+// whose users are lgtm and approve. The lgtm records used when more than one lgtm is required
+// are checked separately.
+func staleLabels(labels sets.String, lgtm, approve string, cfg *botConfig) []string {
+	var r []string
+
+	if cfg.LgtmCountsRequired <= 1 && labels.Has(lgtmLabel) && lgtm == "" {
+		r = append(r, lgtmLabel)
+	}
+
+	if labels.Has(approvedLabel) && approve == "" {
+		r = append(r, approvedLabel)
+	}
+
+	return r
+}
+
+// reviewLabelTriggers returns the users whose commands made the robot add the lgtm and approved
+// labels of PR and are still in effect. The user is empty if the label is not on PR or it is not
+// backed by such a command any more, such as the command was edited away or deleted.
+func reviewLabelTriggers(
+	cli iClient, pid, mrID int, notes []*gitlab.Note, state reviewState, labels sets.String,
+	cfg *botConfig, bot string, log *logrus.Entry,
+) (string, string, error) {
+	needLGTM := cfg.LgtmCountsRequired <= 1 && labels.Has(lgtmLabel)
+	if !needLGTM && !labels.Has(approvedLabel) {
+		return "", "", nil
+	}
+
+	ops, err := cli.GetMergeRequestLabelChanges(pid, mrID)
+	if err != nil {
+		return "", "", err
+	}
+
+	lgtm, approve := "", ""
+
+	if needLGTM {
+		if u := labelTrigger(notes, ops, lgtmLabel, bot, log, cmdLGTM); state.lgtm.Has(u) {
+			lgtm = u
+		}
+	}
+
+	if labels.Has(approvedLabel) {
+		if u := labelTrigger(notes, ops, approvedLabel, bot, log, cmdApprove, cmdApproved); state.approve.Has(u) {
+			approve = u
+		}
+	}
+
+	return lgtm, approve, nil
+}
+
+// acceptedReviews returns the people whose /lgtm and /approve have been accepted by the robot,
+// which are in lower case. The people giving lgtm are the lgtm records when more than one lgtm
+// is required, otherwise the one whose command made the robot add the lgtm label. The people
+// giving approval are the one whose command made the robot add the approved label, except the
+// author of PR.
+func (m *mergeHelper) acceptedReviews(notes []*gitlab.Note, log *logrus.Entry) (sets.String, sets.String, error) {
+	bot, err := m.directory.botLogin(m.cli)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lgtm := sets.NewString()
+	approve := sets.NewString()
+
+	if m.cfg.LgtmCountsRequired > 1 {
+		r, ok, err := findLGTMRecords(notes, bot)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if ok {
+			lgtm.Insert(r.users.UnsortedList()...)
+		}
+	}
+
+	state := newReviewState(notes, m.mr.Author.ID)
+
+	u, v, err := reviewLabelTriggers(
+		m.cli, m.pid, m.mrID, notes, state, m.getMRLabels(), m.cfg, bot, log,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if u != "" {
+		lgtm.Insert(strings.ToLower(u))
+	}
+
+	if v != "" && !strings.EqualFold(v, m.author) {
+		approve.Insert(strings.ToLower(v))
+	}
+
+	return lgtm, approve, nil
+}
+
+// labelTrigger returns the user whose command made the robot add the label at the last time,
+// which is the latest one given before the label was added. The robot replies to a command
+// it refuses before any label is added, so the command is not the trigger if the robot has
+// commented between them. It makes sure that a refused command does not back the label when
+// the accepted one is deleted. It returns empty if the trigger is not found.
+func labelTrigger(
+	notes []*gitlab.Note, ops []*gitlab.LabelEvent, label, bot string, log *logrus.Entry, names ...string,
+) string {
+	added, ok := getLatestLog(ops, label, log)
+	if !ok || !strings.EqualFold(added.who, bot) {
+		return ""
+	}
+
+	cmds := sets.NewString(names...)
+	who := ""
+	var at time.Time
+
+	for _, n := range notes {
+		t := commandTime(n)
+		if n.System || t == nil || strings.EqualFold(n.Author.Username, bot) || t.After(added.t) || t.Before(at) {
+			continue
+		}
+
+		for _, cmd := range parseCommands(n.Body) {
+			if cmd.hasArgs() && cmds.Has(cmd.name) {
+				who = n.Author.Username
+				at = *t
+			}
+		}
+	}
+
+	if who == "" {
+		return ""
+	}
+
+	for _, n := range notes {
+		if !n.System && strings.EqualFold(n.Author.Username, bot) && n.CreatedAt != nil &&
+			n.CreatedAt.After(at) && n.CreatedAt.Before(added.t) {
+			return ""
+		}
+	}
+
+	return who
+}
+
+// commandTime returns the time when the commands of note were given, which is the time
+// when it was edited at the last time.
+func commandTime(n *gitlab.Note) *time.Time {
+	if n.UpdatedAt != nil {
+		return n.UpdatedAt
+	}
+
+	return n.CreatedAt
+}
+
+func isCommentEdited(e *gitlab.MergeCommentEvent) bool {
+	return e.ObjectAttributes.UpdatedAt != e.ObjectAttributes.CreatedAt
+}
+
+// handleCommentEdit re-evaluates the review commands when a comment is edited.
+// The labels whose comments were edited away are revoked, and the /lgtm or /approve
+// in the edited comment are handled as if they were commented just now, unless the
+// comment was written before the latest commits were pushed.
+// The other commands in the comment are not triggered again.
+func (bot *robot) handleCommentEdit(e *gitlab.MergeCommentEvent, cfg *botConfig, log *logrus.Entry) error {
+	if e.ObjectKind != "note" || e.MergeRequest.State != gitlabclient.ActionOpened {
+		return nil
+	}
+
+	state, labels, err := bot.reconcileReviewLabels(cfg, e.ProjectID, e.MergeRequest.IID, e.MergeRequest.AuthorID, log)
+	if err != nil {
+		return err
+	}
+
+	if state.beforePush.Has(e.ObjectAttributes.ID) {
+		return nil
+	}
+
+	lgtm, approve := false, false
+
+	for _, cmd := range parseCommands(gitlabclient.GetMRCommentBody(e)) {
+		switch cmd.name {
+		case cmdLGTM:
+			lgtm = cmd.hasArgs()
+		case cmdApprove, cmdApproved:
+			approve = cmd.hasArgs()
+		}
+	}
+
+	editor := gitlabclient.GetMRCommentAuthor(e)
+
+	merr := utils.NewMultiErrors()
+
+	// the later comments of editor may have canceled the command of the edited one.
+	// addLGTM skips the editor who has been in the lgtm records.
+	if lgtm && state.lgtm.Has(editor) && (cfg.LgtmCountsRequired > 1 || !labels.Has(lgtmLabel)) {
+		if err := bot.addLGTM(cfg, e, log); err != nil {
+			merr.AddError(err)
+		}
+	}
+
+	if approve && state.approve.Has(editor) && !labels.Has(approvedLabel) {
+		if err := bot.AddApprove(cfg, e, log); err != nil {
+			merr.AddError(err)
+		}
+	}
+
+	return merr.Err()
+}
+
+// reconcileReviewLabels removes the review labels which are not backed by the accepted
+// commands in the current comments of PR. It returns the review state and the remaining labels.
+func (bot *robot) reconcileReviewLabels(
+	cfg *botConfig, pid, number, authorID int, log *logrus.Entry,
+) (reviewState, sets.String, error) {
+	notes, err := bot.cli.ListMergeRequestComments(pid, number)
+	if err != nil {
+		return reviewState{}, nil, err
+	}
+
+	mrLabels, err := bot.cli.GetMergeRequestLabels(pid, number)
+	if err != nil {
+		return reviewState{}, nil, err
+	}
+
+	state := newReviewState(notes, authorID)
+	labels := sets.NewString(mrLabels...)
+
+	var revoked []string
+
+	if cfg.LgtmCountsRequired > 1 {
+		if revoked, err = bot.revokeLGTMRecords(cfg, pid, number, authorID, state); err != nil {
+			return state, labels, err
+		}
+	}
+
+	login, err := bot.directory.botLogin(bot.cli)
+	if err != nil {
+		return state, labels, err
+	}
+
+	lgtm, approve, err := reviewLabelTriggers(bot.cli, pid, number, notes, state, labels, cfg, login, log)
+	if err != nil {
+		return state, labels, err
+	}
+
+	if stale := staleLabels(labels, lgtm, approve, cfg); len(stale) > 0 {
+		if err := bot.cli.RemoveMergeRequestLabel(pid, number, stale); err != nil {
+			return state, labels, err
+		}
+
+		labels.Delete(stale...)
+		revoked = append(revoked, stale...)
+	}
+
+	if len(revoked) == 0 {
+		return state, labels, nil
+	}
+
+	err = bot.postActionComment(
+		cfg, pid, number, cfg.message(commentRevokedByEdit, messageArgs{"Labels": strings.Join(revoked, ", ")}),
+	)
+
+	return state, labels, err
+}
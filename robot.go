@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	"github.com/opensourceways/community-robot-lib/gitlabclient"
 	"github.com/xanzy/go-gitlab"
 
@@ -17,28 +19,71 @@ type iClient interface {
 	RemoveMergeRequestLabel(projectID interface{}, mrID int, labels gitlab.Labels) error
 	GetProjectLabels(projectID interface{}) ([]*gitlab.Label, error)
 	CreateProjectLabel(pid interface{}, label, color string) error
+	UpdateMergeRequestComment(projectID interface{}, mrID, noteID int, comment string) error
 	AddMergeRequestLabel(projectID interface{}, mrID int, labels gitlab.Labels) error
-	GetUserPermissionOfProject(projectID interface{}, userID int) (bool, error)
+	GetUserAccessLevelOfProject(projectID interface{}, userID int) (gitlab.AccessLevelValue, error)
 	GetMergeRequestChanges(projectID interface{}, mrID int) ([]string, error)
-	MergeMergeRequest(projectID interface{}, mrID int) error
+	AcceptMergeRequest(projectID interface{}, mrID int, opts gitlab.AcceptMergeRequestOptions) (*gitlab.MergeRequest, error)
 	ListMergeRequestComments(projectID interface{}, mrID int) ([]*gitlab.Note, error)
 	GetMergeRequestLabelChanges(projectID interface{}, mrID int) ([]*gitlab.LabelEvent, error)
 	GetMergeRequest(projectID interface{}, mrID int) (gitlab.MergeRequest, error)
+	GetMergeRequestWithOptions(projectID interface{}, mrID int, opts gitlab.GetMergeRequestsOptions) (gitlab.MergeRequest, error)
 	UpdateMergeRequest(projectID interface{}, mrID int, options gitlab.UpdateMergeRequestOptions) (gitlab.MergeRequest, error)
 	GetPathContent(projectID interface{}, file, branch string) (*gitlab.File, error)
 	GetDirectoryTree(projectID interface{}, opts gitlab.ListTreeOptions) ([]*gitlab.TreeNode, error)
 	GetGroups() ([]*gitlab.Group, error)
 	GetProjects(gid interface{}) ([]*gitlab.Project, error)
+	GetGroupMembers(gid interface{}) ([]*gitlab.GroupMember, error)
+	SearchUsers(search string) ([]*gitlab.User, error)
+	GetCurrentUser() (*gitlab.User, error)
+	GetProject(projectID interface{}) (*gitlab.Project, error)
+	CreateIssue(projectID interface{}, opts gitlab.CreateIssueOptions) (*gitlab.Issue, error)
+	ListProjectIssues(projectID interface{}, opts gitlab.ListProjectIssuesOptions) ([]*gitlab.Issue, error)
+	UpdateIssue(projectID interface{}, issueID int, opts gitlab.UpdateIssueOptions) (*gitlab.Issue, error)
+	SetCommitStatus(projectID interface{}, sha string, opts gitlab.SetCommitStatusOptions) error
+	RebaseMergeRequest(projectID interface{}, mrID int) error
+	ListProjectMergeRequests(projectID interface{}, opts gitlab.ListProjectMergeRequestsOptions) ([]*gitlab.MergeRequest, error)
 }
 
 func newRobot(cli iClient, cacheCli *cache.SDK, gc func() (*configuration, error)) *robot {
-	return &robot{cli: cli, cacheCli: cacheCli, getConfig: gc}
+	return &robot{
+		cli:       cli,
+		cacheCli:  cacheCli,
+		getConfig: gc,
+		commands:  commandRegistry(),
+		scheduler: newScheduler(),
+		directory: newDirectoryCache(directoryTTL),
+		rebases:   newBotRebases(),
+		lgtmLocks: newPRLocks(),
+	}
 }
 
 type robot struct {
 	cli       iClient
 	cacheCli  *cache.SDK
 	getConfig func() (*configuration, error)
+	commands  []commandSpec
+	scheduler *scheduler
+	directory *directoryCache
+	rebases   *botRebases
+	lgtmLocks *prLocks
+
+	reconciler *reconciler
+}
+
+// startReconciler starts to re-evaluate the open PRs every interval.
+func (bot *robot) startReconciler(interval time.Duration, qps float64) {
+	bot.reconciler = newReconciler(bot, interval, qps)
+	bot.reconciler.start()
+}
+
+// stop cancels the pending tasks of robot and waits for the running ones.
+func (bot *robot) stop() {
+	if bot.reconciler != nil {
+		bot.reconciler.stop()
+	}
+
+	bot.scheduler.stop()
 }
 
 func (bot *robot) HandleMergeEvent(e *gitlab.MergeEvent, log *logrus.Entry) error {
@@ -50,7 +95,7 @@ func (bot *robot) HandleMergeEvent(e *gitlab.MergeEvent, log *logrus.Entry) erro
 	botCfg := c.configFor(org, repo)
 
 	merr := utils.NewMultiErrors()
-	if err := bot.clearLabel(e); err != nil {
+	if err := bot.clearLabel(e, botCfg); err != nil {
 		merr.AddError(err)
 	}
 
@@ -66,6 +111,14 @@ func (bot *robot) HandleMergeEvent(e *gitlab.MergeEvent, log *logrus.Entry) erro
 		merr.AddError(err)
 	}
 
+	if err := bot.handleMerged(e, botCfg, log); err != nil {
+		merr.AddError(err)
+	}
+
+	if err := bot.syncReviewState(botCfg, e.Project.ID, e.ObjectAttributes.IID, org, log); err != nil {
+		merr.AddError(err)
+	}
+
 	return merr.Err()
 }
 
@@ -78,15 +131,18 @@ func (bot *robot) HandleMergeCommentEvent(e *gitlab.MergeCommentEvent, log *logr
 	botCfg := c.configFor(org, repo)
 
 	merr := utils.NewMultiErrors()
-	if err := bot.handleLGTM(e, botCfg, log); err != nil {
-		merr.AddError(err)
+
+	if isCommentEdited(e) {
+		err = bot.handleCommentEdit(e, botCfg, log)
+	} else {
+		err = bot.handleCommands(e, botCfg, log)
 	}
 
-	if err = bot.handleApprove(e, botCfg, log); err != nil {
+	if err != nil {
 		merr.AddError(err)
 	}
 
-	if err = bot.handleCheckPR(e, botCfg, log); err != nil {
+	if err := bot.syncReviewState(botCfg, e.ProjectID, e.MergeRequest.IID, org, log); err != nil {
 		merr.AddError(err)
 	}
 
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// scheduler runs the tasks after the specified delays. There is at most one
+// pending task for each key, and a new one replaces the pending one.
+type scheduler struct {
+	lock    sync.Mutex
+	timers  map[string]*time.Timer
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{timers: make(map[string]*time.Timer)}
+}
+
+func (s *scheduler) schedule(key string, delay time.Duration, task func()) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stopped {
+		return
+	}
+
+	if t, ok := s.timers[key]; ok && t.Stop() {
+		s.wg.Done()
+	}
+
+	s.wg.Add(1)
+
+	var t *time.Timer
+	t = time.AfterFunc(delay, func() {
+		defer s.wg.Done()
+
+		s.lock.Lock()
+		if s.timers[key] == t {
+			delete(s.timers, key)
+		}
+		s.lock.Unlock()
+
+		task()
+	})
+
+	s.timers[key] = t
+}
+
+// stop cancels the pending tasks and waits for the running ones to finish.
+func (s *scheduler) stop() {
+	s.lock.Lock()
+
+	s.stopped = true
+
+	for k, t := range s.timers {
+		if t.Stop() {
+			s.wg.Done()
+		}
+
+		delete(s.timers, k)
+	}
+
+	s.lock.Unlock()
+
+	s.wg.Wait()
+}
@@ -1,5 +1,10 @@
 package main
 
+import (
+	"strings"
+	"time"
+)
+
 // SigInfos struct.
 type SigInfos struct {
 	Name         string       `json:"name,omitempty"`
@@ -10,14 +15,20 @@ type SigInfos struct {
 	Mentors      []Mentor     `json:"mentors,omitempty"`
 	Maintainers  []Maintainer `json:"maintainers,omitempty"`
 	Repositories []RepoAdmin  `json:"repositories,omitempty"`
+
+	// EmeritusApprovers are the people who have retired. They have no right any more
+	// even if they are still listed in the other fields.
+	EmeritusApprovers []EmeritusApprover `json:"emeritus_approvers,omitempty"`
 }
 
 // Maintainer struct.
 type Maintainer struct {
 	GiteeID      string `json:"gitee_id,omitempty"`
+	GitlabID     string `json:"gitlab_id,omitempty"`
 	Name         string `json:"name,omitempty"`
 	Organization string `json:"organization,omitempty"`
 	Email        string `json:"email,omitempty"`
+	Until        string `json:"until,omitempty"`
 }
 
 // RepoAdmin struct.
@@ -28,34 +39,140 @@ type RepoAdmin struct {
 	Contributors []Contributor `json:"contributor,omitempty"`
 }
 
+// has reports whether the repository, such as 'org/repo', is one of Repo.
+func (r *RepoAdmin) has(repo string) bool {
+	for _, v := range r.Repo {
+		if strings.EqualFold(v, repo) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Contributor struct.
 type Contributor struct {
 	GiteeID      string `json:"gitee_id,omitempty"`
+	GitlabID     string `json:"gitlab_id,omitempty"`
 	Name         string `json:"name,omitempty"`
 	Organization string `json:"organization,omitempty"`
 	Email        string `json:"email,omitempty"`
+	Until        string `json:"until,omitempty"`
 }
 
 // Mentor struct.
 type Mentor struct {
 	GiteeID      string `json:"gitee_id,omitempty"`
+	GitlabID     string `json:"gitlab_id,omitempty"`
 	Name         string `json:"name,omitempty"`
 	Organization string `json:"organization,omitempty"`
 	Email        string `json:"email,omitempty"`
+	Until        string `json:"until,omitempty"`
 }
 
 // Committer struct.
 type Committer struct {
 	GiteeID      string `json:"gitee_id,omitempty"`
+	GitlabID     string `json:"gitlab_id,omitempty"`
 	Name         string `json:"name,omitempty"`
 	Organization string `json:"organization,omitempty"`
 	Email        string `json:"email,omitempty"`
+	Until        string `json:"until,omitempty"`
 }
 
 // Admin struct.
 type Admin struct {
 	GiteeID      string `json:"gitee_id,omitempty"`
+	GitlabID     string `json:"gitlab_id,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Organization string `json:"organization,omitempty"`
+	Email        string `json:"email,omitempty"`
+	Until        string `json:"until,omitempty"`
+}
+
+// EmeritusApprover struct.
+type EmeritusApprover struct {
+	GiteeID      string `json:"gitee_id,omitempty"`
+	GitlabID     string `json:"gitlab_id,omitempty"`
 	Name         string `json:"name,omitempty"`
 	Organization string `json:"organization,omitempty"`
 	Email        string `json:"email,omitempty"`
+	Until        string `json:"until,omitempty"`
+}
+
+// sigPerson is a person in the sig-info file. It has the same fields as Maintainer, Mentor,
+// Admin, Committer, Contributor and EmeritusApprover, so that they can be converted to it.
+type sigPerson struct {
+	GiteeID      string `json:"gitee_id,omitempty"`
+	GitlabID     string `json:"gitlab_id,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Organization string `json:"organization,omitempty"`
+	Email        string `json:"email,omitempty"`
+
+	// Until is the date until which the person is valid, such as 2024-12-31.
+	Until string `json:"until,omitempty"`
+}
+
+// login returns the GitLab ID of person. The Gitee ID mapped by the identities
+// is used if it is not set, since most of the files are written for Gitee.
+// It returns empty if the person has expired.
+func (p sigPerson) login(ids *identities) string {
+	if isExpired(p.Until, time.Now()) {
+		return ""
+	}
+
+	if p.GitlabID != "" || p.GiteeID == "" {
+		return p.GitlabID
+	}
+
+	return ids.gitlabLogin(p.GiteeID, p.Email)
+}
+
+// is reports whether the person p is the same one as person by the GitLab ID or the Gitee ID.
+func (p sigPerson) is(person sigPerson) bool {
+	return (p.GitlabID != "" && strings.EqualFold(p.GitlabID, person.GitlabID)) ||
+		(p.GiteeID != "" && strings.EqualFold(p.GiteeID, person.GiteeID))
+}
+
+// loginOf returns the GitLab ID of person like sigPerson.login. It returns empty
+// if the person is one of the emeritus approvers of sig.
+func (s *SigInfos) loginOf(p sigPerson, ids *identities) string {
+	for i := range s.EmeritusApprovers {
+		if sigPerson(s.EmeritusApprovers[i]).is(p) {
+			return ""
+		}
+	}
+
+	return p.login(ids)
+}
+
+// persons returns all of the people in the sig-info file.
+func (s *SigInfos) persons() []sigPerson {
+	var r []sigPerson
+
+	for i := range s.Maintainers {
+		r = append(r, sigPerson(s.Maintainers[i]))
+	}
+
+	for i := range s.Mentors {
+		r = append(r, sigPerson(s.Mentors[i]))
+	}
+
+	for i := range s.Repositories {
+		repo := &s.Repositories[i]
+
+		for j := range repo.Admins {
+			r = append(r, sigPerson(repo.Admins[j]))
+		}
+
+		for j := range repo.Committers {
+			r = append(r, sigPerson(repo.Committers[j]))
+		}
+
+		for j := range repo.Contributors {
+			r = append(r, sigPerson(repo.Contributors[j]))
+		}
+	}
+
+	return r
 }
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const defaultSigDepth = 1
+
+// sigLayout specifies how the directories of sigs are organized under sigs_dir.
+type sigLayout struct {
+	// Depth is the max depth of the directories under sigs_dir which can have their own
+	// OWNERS files, such as 2 for sig/<name>/<repo-group>. The files deeper than it are
+	// owned by their ancestor at the depth. The default value is 1 which means sig/<name>.
+	Depth int `json:"depth,omitempty"`
+
+	// OwnersFiles are the patterns of OWNERS files relative to sigs_dir, such as '*/*/OWNERS'.
+	// The default value is the OWNERS files of all directories within Depth.
+	OwnersFiles []string `json:"owners_files,omitempty"`
+
+	// SigInfoFiles are the patterns of sig-info files relative to sigs_dir, which must be
+	// in the directories of sigs, such as 'sig-*/sig-info.yaml'. The default value is '*/sig-info.yaml'.
+	SigInfoFiles []string `json:"sig_info_files,omitempty"`
+}
+
+func (l *sigLayout) setDefault() {
+	if l.Depth == 0 {
+		l.Depth = defaultSigDepth
+	}
+
+	if len(l.OwnersFiles) == 0 {
+		for i := 1; i <= l.Depth; i++ {
+			l.OwnersFiles = append(l.OwnersFiles, strings.Repeat("*/", i)+ownerFile)
+		}
+	}
+
+	if len(l.SigInfoFiles) == 0 {
+		l.SigInfoFiles = []string{"*/" + sigInfoFile}
+	}
+}
+
+func (l *sigLayout) validate() error {
+	if l.Depth < 1 {
+		return fmt.Errorf("depth of sig_layout must be positive")
+	}
+
+	for _, p := range append(append([]string{}, l.OwnersFiles...), l.SigInfoFiles...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern of sig_layout:%s", p)
+		}
+	}
+
+	// the sig-info files are keyed by the directories of sigs.
+	for _, p := range l.SigInfoFiles {
+		if strings.Count(p, "/") != 1 {
+			return fmt.Errorf("the sig-info files must be in the directories of sigs:%s", p)
+		}
+	}
+
+	return nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sigsRoot returns sigs_dir without the trailing slash.
+func (c *botConfig) sigsRoot() string {
+	return strings.TrimSuffix(c.SigsDir, "/")
+}
+
+// isSigOwnersFile reports whether the file, such as 'sig/<name>/OWNERS', is an OWNERS file of the layout.
+func (c *botConfig) isSigOwnersFile(file string) bool {
+	return path.Base(file) == ownerFile &&
+		matchAny(c.SigLayout.OwnersFiles, strings.TrimPrefix(file, c.sigsRoot()+"/"))
+}
+
+// isSigInfoFile reports whether the file, such as 'sig/<name>/sig-info.yaml', is a sig-info file of the layout.
+func (c *botConfig) isSigInfoFile(file string) bool {
+	return path.Base(file) == sigInfoFile &&
+		matchAny(c.SigLayout.SigInfoFiles, strings.TrimPrefix(file, c.sigsRoot()+"/"))
+}
+
+// sigDirOf returns the directory of the sig which the file belongs to, such as 'sig/<name>'.
+// It returns empty if the file is not in any directory of sig.
+func (c *botConfig) sigDirOf(file string) string {
+	if c.SigsDir == "" || !c.regSigDir.MatchString(file) {
+		return ""
+	}
+
+	rel := strings.TrimPrefix(file, c.sigsRoot()+"/")
+
+	return path.Join(c.sigsRoot(), strings.SplitN(rel, "/", 2)[0])
+}
+
+// ownerDirsOf returns the directories whose owners own the file in the order from the deepest
+// one within the depth of layout to the directory of sig, so that the owners of a subdirectory
+// fall back to the ones of its parents.
+func (c *botConfig) ownerDirsOf(file string) []string {
+	if c.sigDirOf(file) == "" {
+		return nil
+	}
+
+	root := c.sigsRoot()
+	parts := strings.Split(path.Dir(strings.TrimPrefix(file, root+"/")), "/")
+
+	if len(parts) > c.SigLayout.Depth {
+		parts = parts[:c.SigLayout.Depth]
+	}
+
+	r := make([]string, 0, len(parts))
+	for i := len(parts); i > 0; i-- {
+		r = append(r, path.Join(root, path.Join(parts[:i]...)))
+	}
+
+	return r
+}
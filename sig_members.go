@@ -0,0 +1,154 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// The roles of people in the sig-info file.
+const (
+	sigRoleMaintainer  = "maintainer"
+	sigRoleMentor      = "mentor"
+	sigRoleAdmin       = "admin"
+	sigRoleCommitter   = "committer"
+	sigRoleContributor = "contributor"
+)
+
+var sigRoles = sets.NewString(sigRoleMaintainer, sigRoleMentor, sigRoleAdmin, sigRoleCommitter, sigRoleContributor)
+
+const defaultSigsBranch = "master"
+
+// sigMember is a person listed in the sig-info files.
+type sigMember struct {
+	roles         sets.String
+	organizations sets.String
+}
+
+// sigsSource returns the project and the branch holding the sig-info files, which are the ones
+// of sigs_repo if it is set, otherwise the project of PR and the branch.
+func (c *botConfig) sigsSource(pid int, branch string) (interface{}, string) {
+	if c.SigsRepo != "" {
+		return c.SigsRepo, c.SigsBranch
+	}
+
+	return pid, branch
+}
+
+// loadSigsOfPR loads the sig-info files of the sigs whose directories are changed
+// by PR or which own the repository of PR. The branch is the target branch of PR.
+func loadSigsOfPR(cli iClient, cfg *botConfig, pid, mrID int, branch, repo string, log *logrus.Entry) []SigInfos {
+	if cfg.SigsDir == "" {
+		return nil
+	}
+
+	project, branch := cfg.sigsSource(pid, branch)
+
+	_, sPath, err := listDirectoryTree(cli, project, branch, cfg)
+	if err != nil {
+		log.WithError(err).Error("list sig-info files")
+
+		return nil
+	}
+
+	dirs := sets.NewString()
+	if changes, err := cli.GetMergeRequestChanges(pid, mrID); err == nil {
+		for _, f := range changes {
+			if d := cfg.sigDirOf(f); d != "" {
+				dirs.Insert(d)
+			}
+		}
+	}
+
+	var r []SigInfos
+
+	for _, s := range sPath {
+		f, err := cli.GetPathContent(project, s, branch)
+		if err != nil || f == nil {
+			continue
+		}
+
+		info, err := parseSigInfoFile(f.Content)
+		if err != nil {
+			log.WithError(err).Errorf("parse %s", s)
+
+			continue
+		}
+
+		if dirs.Has(cfg.sigDirOf(s)) || info.ownsRepo(repo) {
+			r = append(r, info)
+		}
+	}
+
+	return r
+}
+
+func (s *SigInfos) ownsRepo(repo string) bool {
+	for i := range s.Repositories {
+		if s.Repositories[i].has(repo) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sigMembersOf returns the people of sigs with their roles and organizations, which is keyed
+// by the lower case of GitLab login. The admins, committers and contributors are the ones of repo.
+func sigMembersOf(sigs []SigInfos, repo string, ids *identities) map[string]*sigMember {
+	r := make(map[string]*sigMember)
+
+	add := func(login, org, role string) {
+		if login == "" {
+			return
+		}
+
+		login = strings.ToLower(login)
+
+		v, ok := r[login]
+		if !ok {
+			v = &sigMember{roles: sets.NewString(), organizations: sets.NewString()}
+			r[login] = v
+		}
+
+		v.roles.Insert(role)
+
+		if org != "" {
+			v.organizations.Insert(org)
+		}
+	}
+
+	for i := range sigs {
+		s := &sigs[i]
+
+		for _, v := range s.Maintainers {
+			add(s.loginOf(sigPerson(v), ids), v.Organization, sigRoleMaintainer)
+		}
+
+		for _, v := range s.Mentors {
+			add(s.loginOf(sigPerson(v), ids), v.Organization, sigRoleMentor)
+		}
+
+		for j := range s.Repositories {
+			ra := &s.Repositories[j]
+			if !ra.has(repo) {
+				continue
+			}
+
+			for _, v := range ra.Admins {
+				add(s.loginOf(sigPerson(v), ids), v.Organization, sigRoleAdmin)
+			}
+
+			for _, v := range ra.Committers {
+				add(s.loginOf(sigPerson(v), ids), v.Organization, sigRoleCommitter)
+			}
+
+			for _, v := range ra.Contributors {
+				add(s.loginOf(sigPerson(v), ids), v.Organization, sigRoleContributor)
+			}
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/opensourceways/community-robot-lib/gitlabclient"
+	"github.com/opensourceways/community-robot-lib/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/xanzy/go-gitlab"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// statusNoteMarker is a hidden mark used to locate the review status comment of PR.
+const statusNoteMarker = "<!-- review-status -->"
+
+// postActionComment posts the comment about what the robot has just done.
+// It is skipped when the sticky status comment is enabled, because the
+// status comment will be refreshed at the end of handling the event.
+func (bot *robot) postActionComment(cfg *botConfig, pid, mrID int, comment string) error {
+	if cfg.StickyStatusComment {
+		return nil
+	}
+
+	return bot.cli.CreateMergeRequestComment(pid, mrID, comment)
+}
+
+// syncReviewState publishes the current review state of PR at the end of handling an event,
+// both as the sticky status comment and as the commit status, if they are enabled.
+func (bot *robot) syncReviewState(cfg *botConfig, pid, mrID int, org string, log *logrus.Entry) error {
+	if !cfg.StickyStatusComment && !cfg.ReportCommitStatus {
+		return nil
+	}
+
+	mr, err := getMergeRequest(bot.cli, pid, mrID)
+	if err != nil || mr.State != gitlabclient.ActionOpened {
+		return err
+	}
+
+	h := mergeHelper{
+		cfg:       cfg,
+		pid:       pid,
+		mrID:      mrID,
+		org:       org,
+		author:    mr.Author.Username,
+		cli:       bot.cli,
+		directory: bot.directory,
+		mr:        &mr,
+	}
+
+	merr := utils.NewMultiErrors()
+
+	if err := h.refreshStatusNote(log); err != nil {
+		merr.AddError(err)
+	}
+
+	if err := h.publishCommitStatus(log); err != nil {
+		merr.AddError(err)
+	}
+
+	return merr.Err()
+}
+
+// refreshStatusNote updates the review status comment of PR in place, or creates it if missing.
+func (m *mergeHelper) refreshStatusNote(log *logrus.Entry) error {
+	if !m.cfg.StickyStatusComment {
+		return nil
+	}
+
+	notes, err := m.cli.ListMergeRequestComments(m.pid, m.mrID)
+	if err != nil {
+		return err
+	}
+
+	bot, err := m.directory.botLogin(m.cli)
+	if err != nil {
+		return err
+	}
+
+	body := statusNoteMarker + "\n" + m.genStatus(notes, log)
+
+	// the marker may be quoted in the comments of others.
+	for _, n := range notes {
+		if n.System || !strings.EqualFold(n.Author.Username, bot) || !strings.Contains(n.Body, statusNoteMarker) {
+			continue
+		}
+
+		if n.Body == body {
+			return nil
+		}
+
+		return m.cli.UpdateMergeRequestComment(m.pid, m.mrID, n.ID, body)
+	}
+
+	return m.cli.CreateMergeRequestComment(m.pid, m.mrID, body)
+}
+
+func (m *mergeHelper) genStatus(notes []*gitlab.Note, log *logrus.Entry) string {
+	join := func(v sets.String) string {
+		if v.Len() == 0 {
+			return ""
+		}
+
+		return "@" + strings.Join(v.List(), ", @")
+	}
+
+	lgtm, approve, err := m.acceptedReviews(notes, log)
+	if err != nil {
+		log.WithError(err).Errorf("get the accepted reviews of PR:%d", m.mrID)
+
+		lgtm, approve = sets.NewString(), sets.NewString()
+	}
+
+	labels := sets.NewString(m.mr.Labels...)
+
+	needs := sets.NewString(approvedLabel)
+	needs.Insert(m.cfg.LabelsForMerge...)
+	if m.cfg.LgtmCountsRequired <= 1 {
+		needs.Insert(lgtmLabel)
+	}
+
+	invalid := sets.NewString(m.cfg.MissingLabelsForMerge...).Intersection(labels)
+
+	args := messageArgs{
+		"Reviewers":     join(lgtm),
+		"Approvers":     join(approve),
+		"LgtmCount":     lgtmCountOnPR(labels, m.cfg),
+		"LgtmRequired":  m.cfg.LgtmCountsRequired,
+		"MissingLabels": strings.Join(needs.Difference(labels).List(), ", "),
+		"InvalidLabels": strings.Join(invalid.List(), ", "),
+		"TargetBranch":  m.mr.TargetBranch,
+		"FreezeKnown":   true,
+		"Frozen":        false,
+		"FreezeOwners":  "",
+	}
+
+	freeze, err := m.getFreezeInfo(log)
+	if err != nil {
+		args["FreezeKnown"] = false
+	} else if freeze != nil && freeze.isFrozen() {
+		args["Frozen"] = true
+		args["FreezeOwners"] = strings.Join(freeze.Owner, ", ")
+	}
+
+	reasons, ok := m.canMerge(log)
+	args["Mergeable"] = ok
+	args["Reasons"] = strings.Join(reasons, "\n")
+
+	return m.cfg.message(commentStatus, args)
+}